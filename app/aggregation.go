@@ -0,0 +1,224 @@
+package app
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// outputLine is an output info with its values for the displayed dimensions
+type outputLine struct {
+	relation.OutputInfo
+	dimensions []string
+}
+
+// groupNode is an aggregation level with its nested groups, or its relations
+// for the last level
+type groupNode struct {
+	relation.GroupInfo
+	dimensions []string
+	children   map[string]*groupNode
+	relinfos   []relation.RelInfo
+}
+
+// dimensionHeader returns the column name of the dimension
+func (p *PgPageCache) dimensionHeader(d Dimension) string {
+	switch d {
+	case DimensionDatabase:
+		return "Database"
+	case DimensionSchema:
+		return "Schema"
+	case DimensionTablespace:
+		return "Tablespace"
+	case DimensionOwner:
+		return "Owner"
+	case DimensionAccessMethod:
+		return "Access Method"
+	case DimensionRelkind:
+		return "Relkind"
+	case DimensionPartitionRoot:
+		return "Partition Root"
+	case DimensionPartition:
+		if p.customGroups {
+			return "Group"
+		}
+		return "Partition"
+	case DimensionTable:
+		if p.customGroups {
+			return "SubGroup"
+		}
+		return "Table"
+	case DimensionContainer:
+		return "Container"
+	}
+	return "Unknown"
+}
+
+// dimensionValue returns the relation's value for the dimension
+func (p *PgPageCache) dimensionValue(d Dimension, r *relation.RelInfo) string {
+	switch d {
+	case DimensionDatabase:
+		return p.database
+	case DimensionSchema:
+		return r.Namespace
+	case DimensionTablespace:
+		return r.Tablespace
+	case DimensionOwner:
+		return r.Owner
+	case DimensionAccessMethod:
+		return r.AccessMethod
+	case DimensionRelkind:
+		return relation.KindToString(r.Kind)
+	case DimensionPartitionRoot:
+		return r.PartitionRoot
+	case DimensionPartition:
+		return r.Partition
+	case DimensionTable:
+		return r.Table
+	case DimensionContainer:
+		return p.Container
+	}
+	return ""
+}
+
+// hasContainerLabel returns true if rows are labelled with the container of
+// -target_pid without aggregating on it
+func (p *PgPageCache) hasContainerLabel() bool {
+	return p.Container != "" && !slices.Contains(p.Aggregation, DimensionContainer)
+}
+
+// displayDimensions returns the dimension columns. Without aggregation,
+// relations are displayed with their partition and table
+func (p *PgPageCache) displayDimensions() []Dimension {
+	dimensions := p.Aggregation
+	if len(dimensions) == 0 {
+		dimensions = []Dimension{DimensionPartition, DimensionTable}
+	}
+	if p.hasContainerLabel() {
+		dimensions = append([]Dimension{DimensionContainer}, dimensions...)
+	}
+	return dimensions
+}
+
+// padDimensions fills missing dimension values with empty strings, after
+// the container label
+func (p *PgPageCache) padDimensions(values []string) []string {
+	if p.hasContainerLabel() {
+		values = append([]string{p.Container}, values...)
+	}
+	res := make([]string, len(p.displayDimensions()))
+	copy(res, values)
+	return res
+}
+
+// relinfoLine returns the output line of a relation with all its dimension values
+func (p *PgPageCache) relinfoLine(r *relation.RelInfo) outputLine {
+	var values []string
+	for _, d := range p.displayDimensions() {
+		values = append(values, p.dimensionValue(d, r))
+	}
+	return outputLine{r, values}
+}
+
+func (p *PgPageCache) sortGroupNodes(r []*groupNode) {
+	slices.SortFunc(r, func(a, b *groupNode) int {
+		switch p.Sort {
+		case SortPageCount:
+			return cmp.Or(cmp.Compare(b.PageCount, a.PageCount), cmp.Compare(a.Name, b.Name))
+		case SortPageCached:
+			return cmp.Or(cmp.Compare(b.PageCached, a.PageCached), cmp.Compare(a.Name, b.Name))
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// getRelInfos returns all relations
+func (p *PgPageCache) getRelInfos() (relinfos []relation.RelInfo) {
+	for _, partInfo := range p.partitions {
+		for _, tableInfo := range partInfo.TableInfos {
+			relinfos = append(relinfos, tableInfo.RelInfos...)
+		}
+	}
+	return
+}
+
+// getNoAggregations returns all relations, sorted and limited
+func (p *PgPageCache) getNoAggregations() (lines []outputLine) {
+	relinfos := p.getRelInfos()
+	p.sortRelInfos(relinfos)
+
+	for i := range relinfos {
+		if p.Limit > 0 && i >= p.Limit {
+			break
+		}
+		lines = append(lines, p.relinfoLine(&relinfos[i]))
+		relation.TotalInfo.Add(relinfos[i].PageStats)
+	}
+	return
+}
+
+// buildGroups aggregates relations in nested groups following the aggregation dimensions
+func (p *PgPageCache) buildGroups() *groupNode {
+	root := &groupNode{children: make(map[string]*groupNode, 0)}
+	for _, relinfo := range p.getRelInfos() {
+		node := root
+		for _, d := range p.Aggregation {
+			value := p.dimensionValue(d, &relinfo)
+			child, ok := node.children[value]
+			if !ok {
+				child = &groupNode{
+					GroupInfo:  relation.GroupInfo{BaseInfo: relation.BaseInfo{Name: value}, Dimension: p.dimensionHeader(d)},
+					dimensions: append(slices.Clone(node.dimensions), value),
+					children:   make(map[string]*groupNode, 0),
+				}
+				node.children[value] = child
+			}
+			child.Add(relinfo.PageStats)
+			node = child
+		}
+		node.relinfos = append(node.relinfos, relinfo)
+	}
+	return root
+}
+
+// appendGroupLines outputs groups with their subtotals, followed by their
+// nested groups or relations. Limit applies on top level groups
+func (p *PgPageCache) appendGroupLines(node *groupNode, lines []outputLine) []outputLine {
+	children := slices.Collect(maps.Values(node.children))
+	p.sortGroupNodes(children)
+
+	for i, child := range children {
+		if len(node.dimensions) == 0 {
+			if p.Limit > 0 && i >= p.Limit {
+				break
+			}
+			relation.TotalInfo.Add(child.PageStats)
+		}
+
+		lines = append(lines, outputLine{&child.GroupInfo, p.padDimensions(child.dimensions)})
+		if len(child.children) > 0 {
+			lines = p.appendGroupLines(child, lines)
+			continue
+		}
+
+		if p.GroupTable {
+			// Skip printing relations
+			continue
+		}
+		p.sortRelInfos(child.relinfos)
+		for j := range child.relinfos {
+			lines = append(lines, p.relinfoLine(&child.relinfos[j]))
+		}
+	}
+	return lines
+}
+
+// getAggregations returns groups and relations following the aggregation dimensions
+func (p *PgPageCache) getAggregations() []outputLine {
+	if len(p.Aggregation) == 0 {
+		return p.getNoAggregations()
+	}
+	return p.appendGroupLines(p.buildGroups(), nil)
+}
@@ -0,0 +1,97 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// getBtreeInfos returns the page types of cached blocks of each btree index.
+// With pgstatindex, indexes without cached blocks are kept as they are the
+// ones with uncached internal pages
+func (p *PgPageCache) getBtreeInfos(ctx context.Context) (btreeInfos []relation.BtreeInfo, err error) {
+	withInternalTotal := p.Pgstatindex && p.conn != nil
+	relinfos := p.getRelInfos()
+	if withInternalTotal {
+		relinfos = p.scannedRelinfos
+	}
+	for _, relinfo := range relinfos {
+		if relinfo.Kind != 'i' || relinfo.AccessMethod != "btree" {
+			continue
+		}
+		if relinfo.PageCached == 0 && !withInternalTotal {
+			continue
+		}
+		b := relation.BtreeInfo{Name: relinfo.Name, Oid: relinfo.Oid}
+		err = p.forEachCachedBlock(relinfo.Relfilenode, func(blockno int64, block []byte) error {
+			header, err := pgdisk.ParsePageHeader(block)
+			if err != nil {
+				return err
+			}
+			if header.IsNew() || !header.IsValid(int(p.bufferSettings.BlockSize)) {
+				slog.Debug("Skipping invalid block", "relation", relinfo.Name, "block", blockno)
+				return nil
+			}
+			pageType, err := pgdisk.GetBtreePageType(block, header)
+			if err != nil {
+				return fmt.Errorf("block %d of %s: %v", blockno, relinfo.Name, err)
+			}
+			b.CachedBlocks++
+			b.Types[pageType]++
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if b.CachedBlocks == 0 && !withInternalTotal {
+			continue
+		}
+		if withInternalTotal {
+			internalPages, err := relation.GetInternalPages(ctx, p.conn, relinfo.Oid)
+			if err != nil {
+				return nil, err
+			}
+			b.InternalTotal = &internalPages
+		}
+		btreeInfos = append(btreeInfos, b)
+	}
+	slices.SortFunc(btreeInfos, func(a, b relation.BtreeInfo) int {
+		return cmp.Or(cmp.Compare(b.CachedBlocks, a.CachedBlocks), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// outputBtreeReport outputs the cached blocks of btree indexes per page type
+func (p *PgPageCache) outputBtreeReport(ctx context.Context) error {
+	err := p.loadBufferSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if p.Pgstatindex && p.conn == nil {
+		slog.Warn("pgstatindex needs a connection, total internal pages won't be reported")
+	}
+	btreeInfos, err := p.getBtreeInfos(ctx)
+	if err != nil {
+		return err
+	}
+
+	total := relation.BtreeInfo{Name: "Total"}
+	var values [][]string
+	for i := range btreeInfos {
+		total.Add(btreeInfos[i])
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, btreeInfos[i].ToStringArray(p.Unit, p.bufferSettings.BlockSize))
+	}
+	values = append(values, total.ToStringArray(p.Unit, p.bufferSettings.BlockSize))
+
+	header := append([]string{"Index", "Cached"}, relation.BtreeHeaders...)
+	header = append(header, "Internal Total", "%Internal Cached")
+	return p.outputReport(header, values)
+}
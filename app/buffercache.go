@@ -0,0 +1,144 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/relation"
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+// getBlockValues returns a value for each block of the relation's segments,
+// being the highest value of its os pages. Non resident pages have a negative value
+func (p *PgPageCache) getBlockValues(relfilenode uint32, settings relation.BufferSettings, getPageValues func(string) ([]int, error)) (blockValues []int, err error) {
+	err = p.forEachSegment(relfilenode, "", func(segno int64, fullPath string) error {
+		pageValues, err := getPageValues(fullPath)
+		if err != nil {
+			return err
+		}
+		// Pad a short previous segment to keep block numbers aligned
+		for int64(len(blockValues)) < segno*settings.SegmentBlocks {
+			blockValues = append(blockValues, -1)
+		}
+
+		segmentBlocks := (int64(len(pageValues))*p.pageSize + settings.BlockSize - 1) / settings.BlockSize
+		for block := range segmentBlocks {
+			start := block * settings.BlockSize / p.pageSize
+			end := min(((block+1)*settings.BlockSize+p.pageSize-1)/p.pageSize, int64(len(pageValues)))
+			blockValues = append(blockValues, slices.Max(pageValues[start:end]))
+		}
+		return nil
+	})
+	return blockValues, err
+}
+
+// getBlockResidency returns the page cache residency of the relation's
+// blocks, a block is resident if any of its os pages is resident
+func (p *PgPageCache) getBlockResidency(relfilenode uint32, settings relation.BufferSettings) ([]bool, error) {
+	blockValues, err := p.getBlockValues(relfilenode, settings, func(fullPath string) ([]int, error) {
+		pageResidency, err := pagecache.GetResidency(fullPath, p.pageSize)
+		pageValues := make([]int, len(pageResidency))
+		for i, resident := range pageResidency {
+			if !resident {
+				pageValues[i] = -1
+			}
+		}
+		return pageValues, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	residency := make([]bool, len(blockValues))
+	for i, v := range blockValues {
+		residency[i] = v >= 0
+	}
+	return residency, nil
+}
+
+// getDoubleBuffering compares blocks in shared_buffers with the page cache residency of each relation
+func (p *PgPageCache) getDoubleBuffering(ctx context.Context) (doubleBuffers []relation.DoubleBufferInfo, err error) {
+	if p.conn == nil {
+		slog.Warn("Double buffering analysis needs a connection, skipping it")
+		return nil, nil
+	}
+	p.bufferSettings, err = relation.GetBufferSettings(ctx, p.conn)
+	if err != nil {
+		return
+	}
+	sharedBuffers, err := relation.GetSharedBuffers(ctx, p.conn)
+	if err != nil {
+		return
+	}
+
+	for _, relinfo := range p.getRelInfos() {
+		residency, err := p.getBlockResidency(relinfo.Relfilenode, p.bufferSettings)
+		if err != nil {
+			return nil, err
+		}
+		d := relation.DoubleBufferInfo{Name: relinfo.Name, Relfilenode: relinfo.Relfilenode}
+		sharedBuffers[relinfo.Relfilenode].ForEach(func(block int64) {
+			if block < int64(len(residency)) && residency[block] {
+				d.Both++
+			} else {
+				d.SharedOnly++
+			}
+		})
+		for _, resident := range residency {
+			if resident {
+				d.OSOnly++
+			}
+		}
+		d.OSOnly -= d.Both
+		if d.Both+d.SharedOnly+d.OSOnly > 0 {
+			doubleBuffers = append(doubleBuffers, d)
+		}
+	}
+
+	p.sortDoubleBuffers(doubleBuffers)
+	return doubleBuffers, nil
+}
+
+// sortDoubleBuffers sorts by page cache blocks for pagecached, by blocks in
+// shared_buffers or page cache for pagecount
+func (p *PgPageCache) sortDoubleBuffers(d []relation.DoubleBufferInfo) {
+	slices.SortFunc(d, func(a, b relation.DoubleBufferInfo) int {
+		switch p.Sort {
+		case SortPageCount:
+			return cmp.Or(cmp.Compare(b.Both+b.SharedOnly+b.OSOnly, a.Both+a.SharedOnly+a.OSOnly), cmp.Compare(a.Name, b.Name))
+		case SortPageCached:
+			return cmp.Or(cmp.Compare(b.Both+b.OSOnly, a.Both+a.OSOnly), cmp.Compare(a.Name, b.Name))
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// applyDoubleBuffering removes the page cache used by blocks already in
+// shared_buffers from the %Total denominator
+func (p *PgPageCache) applyDoubleBuffering() {
+	var total relation.DoubleBufferInfo
+	for _, d := range p.doubleBuffers {
+		total.Add(d)
+	}
+	doubleKB := int64(total.Both) * p.bufferSettings.BlockSize / 1024
+	p.fileMemory = max(p.fileMemory-doubleKB, 0)
+	p.memorySource = fmt.Sprintf("%s - double buffered", p.memorySource)
+	slog.Info("Detected double buffering", "double_buffered", utils.FormatKBValue(doubleKB, utils.UnitMB),
+		"effective_cache_memory", utils.FormatKBValue(p.fileMemory, utils.UnitGB))
+}
+
+// getDoubleBufferValues returns the double buffering rows followed by their total
+func (p *PgPageCache) getDoubleBufferValues() (values [][]string) {
+	total := relation.DoubleBufferInfo{Name: "Total"}
+	for i, v := range p.doubleBuffers {
+		total.Add(v)
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, v.ToStringArray(p.Unit, p.bufferSettings.BlockSize, p.fileMemory))
+	}
+	return append(values, total.ToStringArray(p.Unit, p.bufferSettings.BlockSize, p.fileMemory))
+}
@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// defaultSegmentSize is the default size of relation segments
+const defaultSegmentSize = 1 << 30
+
+// loadBufferSettings fetches block and segment sizes from the server.
+// Without connection, the block size is detected from the relation files
+func (p *PgPageCache) loadBufferSettings(ctx context.Context) (err error) {
+	if p.bufferSettings.BlockSize > 0 {
+		return nil
+	}
+	if p.conn != nil {
+		p.bufferSettings, err = relation.GetBufferSettings(ctx, p.conn)
+		return
+	}
+	baseDir := path.Join(p.PgData, "base", fmt.Sprintf("%d", p.dbid))
+	for _, relinfo := range p.getRelInfos() {
+		blockSize, err := pgdisk.DetectPageSize(path.Join(baseDir, fmt.Sprintf("%d", relinfo.Relfilenode)))
+		if err != nil {
+			// Empty or missing relation file
+			continue
+		}
+		p.bufferSettings = relation.BufferSettings{BlockSize: int64(blockSize), SegmentBlocks: defaultSegmentSize / int64(blockSize)}
+		return nil
+	}
+	return fmt.Errorf("couldn't detect block size from relation files")
+}
+
+// forEachCachedBlock calls fn with each block of the relation's main fork
+// whose os pages are all resident. Readahead is disabled on the segments so
+// reading a block doesn't pull non resident pages in the page cache
+func (p *PgPageCache) forEachCachedBlock(relfilenode uint32, fn func(blockno int64, block []byte) error) error {
+	block := make([]byte, p.bufferSettings.BlockSize)
+	return p.forEachSegment(relfilenode, "", func(segno int64, fullPath string) error {
+		residency, err := pagecache.GetResidency(fullPath, p.pageSize)
+		if err != nil {
+			return err
+		}
+		return p.readCachedBlocks(fullPath, residency, block, func(segBlock int64, block []byte) error {
+			return fn(segno*p.bufferSettings.SegmentBlocks+segBlock, block)
+		})
+	})
+}
+
+// readCachedBlocks reads the fully resident blocks of a segment
+func (p *PgPageCache) readCachedBlocks(fullPath string, residency []bool, block []byte, fn func(int64, []byte) error) error {
+	blockSize := p.bufferSettings.BlockSize
+	segmentBlocks := int64(len(residency)) * p.pageSize / blockSize
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for segBlock := range segmentBlocks {
+		start := segBlock * blockSize / p.pageSize
+		end := ((segBlock+1)*blockSize + p.pageSize - 1) / p.pageSize
+		resident := true
+		for _, pageResident := range residency[start:end] {
+			resident = resident && pageResident
+		}
+		if !resident {
+			continue
+		}
+		if file == nil {
+			var err error
+			file, err = pagecache.OpenRandomAccess(fullPath)
+			if err != nil {
+				return err
+			}
+		}
+		_, err := file.ReadAt(block, segBlock*blockSize)
+		if errors.Is(err, io.EOF) {
+			// Relation was truncated since the residency check
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error reading block %d of %s: %v", segBlock, fullPath, err)
+		}
+		err = fn(segBlock, block)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
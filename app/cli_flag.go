@@ -3,8 +3,12 @@ package app
 import (
 	"flag"
 	"fmt"
+	"log/slog"
 	"os"
+	"slices"
 	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/postmaster"
 )
 
 var (
@@ -24,6 +28,36 @@ type CliArgs struct {
 	Cpuprofile          string
 	RawFlags            bool
 	ScanWal             bool
+	ScanTemp            bool
+	ScanDirs            bool
+	FullWalk            bool
+	Buffercache         bool
+	DoubleBufferingFile string
+	WasteReport         bool
+	StatioReport        bool
+	DirtyReport         bool
+	LsnReport           bool
+	FreeSpaceReport     bool
+	BtreeReport         bool
+	Pgstatindex         bool
+	VisibilityReport    bool
+	WasteScanThreshold  int64
+	AutoprewarmFile     string
+	Impact              bool
+	Query               string
+	Explain             bool
+	StoreTo             string
+	StoreTable          string
+	Offline             bool
+	CatalogCache        string
+	StateFile           string
+	GroupQuery          string
+	Cluster             string
+	TargetPid           int
+	// Root of the target postmaster's mount namespace, empty without -target_pid
+	RootDir string
+	// Container identity of the target postmaster
+	Container string
 
 	FormatFlags
 }
@@ -31,17 +65,49 @@ type CliArgs struct {
 func init() {
 	flag.StringVar(&cliArgs.PgData, "pg_data", "", "Location of pgdata, uses PGDATA env var if not defined")
 	flag.StringVar(&cliArgs.ConnectString, "connect_str", "", "Connection string to PostgreSQL")
-	flag.IntVar(&cliArgs.PageThreshold, "page_threshold", 0, "Exclude relations pages under the threshold. -1 to display everything")
+	flag.StringVar(&cliArgs.Cluster, "cluster", "", "Port or data directory of the discovered cluster to use when multiple postmasters are running")
+	flag.IntVar(&cliArgs.TargetPid, "target_pid", 0, "Pid of a containerized postmaster, paths are resolved through its mount namespace and cached memory is taken from its cgroup")
+	flag.BoolVar(&cliArgs.Offline, "offline", false, "Read catalogs from pg_data files instead of connecting to PostgreSQL")
+	flag.StringVar(&cliArgs.CatalogCache, "catalog_cache", "", "File storing the relation mapping, used when the connection to PostgreSQL fails")
+	flag.StringVar(&cliArgs.StateFile, "state_file", "", "File storing scan results, used to report relations rewritten since the previous run")
+	flag.StringVar(&cliArgs.Database, "database", "", "Database to scan in offline mode, uses PGDATABASE env var or postgres if not defined")
+	flag.IntVar(&cliArgs.PageThreshold, "page_threshold", 0, "Exclude relations with on-disk pages under the threshold. -1 to display everything")
 	flag.IntVar(&cliArgs.CachedPageThreshold, "cached_page_threshold", 0, "Exclude relations with cached pages under the threshold. -1 to display everything")
 	flag.StringVar(&cliArgs.Cpuprofile, "cpuprofile", "", "write cpu profile to `file`")
+	flag.StringVar(&cliArgs.GroupQuery, "group_query", "", "SQL query returning (oid or relfilenode, group_label[, sub_label]) used to group relations instead of partitions and tables")
 	flag.StringVar(&relationsFlag, "relations", "", "Filter on a specific relations (separated with commas)")
 	flag.BoolVar(&cliArgs.RawFlags, "raw_flags", false, "Raw flag mode")
 	flag.BoolVar(&cliArgs.ScanWal, "scan_wal", true, "Scan pagecache usage of WAL files")
+	flag.BoolVar(&cliArgs.Buffercache, "buffercache", false, "Compare shared_buffers content from pg_buffercache with the page cache to report double buffering")
+	flag.StringVar(&cliArgs.DoubleBufferingFile, "double_buffering_file", "", "Write the double buffering section of -buffercache to `file` as csv, required with -format csv")
+	flag.BoolVar(&cliArgs.WasteReport, "waste_report", false, "Report cached relations with no or minimal scans since the last stats reset instead of the page cache usage")
+	flag.Int64Var(&cliArgs.WasteScanThreshold, "waste_scan_threshold", 0, "Maximum number of scans for a relation to be reported by the waste report")
+	flag.StringVar(&cliArgs.AutoprewarmFile, "autoprewarm_file", "", "Export cached blocks to `file` in pg_prewarm's autoprewarm.blocks format instead of displaying the page cache usage")
+	flag.StringVar(&cliArgs.Query, "query", "", "Query run by the impact command")
+	flag.BoolVar(&cliArgs.Explain, "explain", false, "Run the impact command's query with EXPLAIN (ANALYZE, BUFFERS) and display the plan")
+	flag.StringVar(&cliArgs.StoreTo, "store_to", "", "Connection string of the PostgreSQL storing each run's results in the history table")
+	flag.StringVar(&cliArgs.StoreTable, "store_table", "pg_pagecache.history", "History table used by -store_to, created on first use")
+	flag.BoolVar(&cliArgs.StatioReport, "statio_report", false, "Report shared buffers hit ratio of relations with their page cache residency instead of the page cache usage")
+	flag.BoolVar(&cliArgs.DirtyReport, "dirty_report", false, "Report dirty and writeback pages of relations and WAL with a forecast of the next checkpoint writes instead of the page cache usage")
+	flag.BoolVar(&cliArgs.LsnReport, "lsn_report", false, "Report the distribution of page LSN ages of cached blocks instead of the page cache usage")
+	flag.BoolVar(&cliArgs.FreeSpaceReport, "free_space_report", false, "Report tuple data and empty space of cached heap blocks instead of the page cache usage")
+	flag.BoolVar(&cliArgs.BtreeReport, "btree_report", false, "Report cached blocks of btree indexes per page type instead of the page cache usage")
+	flag.BoolVar(&cliArgs.Pgstatindex, "pgstatindex", false, "Use pgstattuple's pgstatindex to get the total number of internal pages in the btree report. Whole indexes are read")
+	flag.BoolVar(&cliArgs.VisibilityReport, "visibility_report", false, "Report cached heap blocks that are all-visible, all-frozen or neither according to the visibility map instead of the page cache usage")
+	flag.BoolVar(&cliArgs.ScanTemp, "scan_temp", true, "Scan pagecache usage of temporary relations and spill files")
+	flag.BoolVar(&cliArgs.FullWalk, "full_walk", false, "Walk all files of pgdata and tablespaces, reporting files not belonging to postgres as unattributed with the coverage of the cached memory")
+	flag.BoolVar(&cliArgs.ScanDirs, "scan_dirs", true, "Scan pagecache usage of other pgdata directories, replication slots spill files and server logs")
 }
 
 // ParseCliArgs returns a CliArgs with parsed values
 func ParseCliArgs() (CliArgs, error) {
-	flag.Parse()
+	if len(os.Args) > 1 && os.Args[1] == "impact" {
+		// impact command, flags follow the command name
+		cliArgs.Impact = true
+		flag.CommandLine.Parse(os.Args[2:])
+	} else {
+		flag.Parse()
+	}
 	err := SetLogLevel()
 	if err != nil {
 		return cliArgs, err
@@ -51,18 +117,117 @@ func ParseCliArgs() (CliArgs, error) {
 		return cliArgs, err
 	}
 
+	if cliArgs.TargetPid != 0 {
+		err = setTargetCluster()
+		if err != nil {
+			return cliArgs, err
+		}
+	}
+
 	if cliArgs.PgData == "" {
 		// Fallback to PGDATA env var
 		var found bool
 		cliArgs.PgData, found = os.LookupEnv("PGDATA")
 		if !found {
-			return cliArgs, fmt.Errorf("pgdata is mandatory")
+			// Fallback to the running postmaster
+			err = discoverCluster()
+			if err != nil {
+				return cliArgs, fmt.Errorf("pgdata is mandatory and discovery failed: %v", err)
+			}
+		}
+	}
+
+	if cliArgs.Offline && cliArgs.Database == "" {
+		// Fallback to PGDATABASE env var
+		var found bool
+		cliArgs.Database, found = os.LookupEnv("PGDATABASE")
+		if !found {
+			cliArgs.Database = "postgres"
 		}
 	}
 
+	if cliArgs.Impact && cliArgs.Query == "" {
+		return cliArgs, fmt.Errorf("impact command needs a query")
+	}
+
+	err = checkReportModes()
+	if err != nil {
+		return cliArgs, err
+	}
+
+	if cliArgs.Buffercache && cliArgs.Type == FormatCSV && cliArgs.DoubleBufferingFile == "" {
+		return cliArgs, fmt.Errorf("-buffercache with -format csv needs -double_buffering_file")
+	}
+
 	if relationsFlag != "" {
 		cliArgs.Relations = strings.Split(relationsFlag, ",")
 	}
 
 	return cliArgs, err
 }
+
+// checkReportModes ensures at most one report replacing the page cache usage is requested
+func checkReportModes() error {
+	modes := map[string]bool{
+		"-waste_report":      cliArgs.WasteReport,
+		"-statio_report":     cliArgs.StatioReport,
+		"-dirty_report":      cliArgs.DirtyReport,
+		"-lsn_report":        cliArgs.LsnReport,
+		"-free_space_report": cliArgs.FreeSpaceReport,
+		"-btree_report":      cliArgs.BtreeReport,
+		"-visibility_report": cliArgs.VisibilityReport,
+		"-autoprewarm_file":  cliArgs.AutoprewarmFile != "",
+	}
+	var requested []string
+	for name, enabled := range modes {
+		if enabled {
+			requested = append(requested, name)
+		}
+	}
+	if len(requested) > 1 {
+		slices.Sort(requested)
+		return fmt.Errorf("only one report can be requested, got %s", strings.Join(requested, ", "))
+	}
+	return nil
+}
+
+// discoverCluster sets pgdata and the connection string from a running postmaster
+func discoverCluster() error {
+	clusters, err := postmaster.Discover()
+	if err != nil {
+		return err
+	}
+	cluster, err := postmaster.Select(clusters, cliArgs.Cluster)
+	if err != nil {
+		return err
+	}
+	slog.Info("Using discovered cluster", "pid", cluster.Pid, "port", cluster.Port, "dataDir", cluster.DataDir)
+	cliArgs.PgData = cluster.DataDir
+	_, hasHost := os.LookupEnv("PGHOST")
+	_, hasPort := os.LookupEnv("PGPORT")
+	if cliArgs.ConnectString == "" && !hasHost && !hasPort {
+		cliArgs.ConnectString = cluster.ConnectString()
+	}
+	return nil
+}
+
+// setTargetCluster resolves pgdata and the connection string through the
+// target postmaster's mount namespace. Provided pg_data is a path in the container
+func setTargetCluster() error {
+	cluster, err := postmaster.FromPid(cliArgs.TargetPid)
+	if err != nil {
+		return fmt.Errorf("error resolving target pid: %v", err)
+	}
+	cliArgs.RootDir = cluster.Root
+	cliArgs.Container = postmaster.ContainerIdentity(cliArgs.TargetPid)
+	slog.Info("Using target postmaster", "pid", cliArgs.TargetPid, "container", cliArgs.Container, "dataDir", cluster.DataDir)
+
+	if cliArgs.PgData == "" {
+		cliArgs.PgData = cluster.DataDir
+	}
+	cliArgs.PgData = cluster.HostPath(cliArgs.PgData)
+	if cliArgs.ConnectString == "" {
+		cliArgs.ConnectString = cluster.ConnectString()
+	}
+	return nil
+}
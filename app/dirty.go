@@ -0,0 +1,190 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/memory"
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+var dirtyHeader = []string{"Relation", "Kind", "Dirty", "Writeback", "%Dirty"}
+
+// relationForks are the fork suffixes of relation files, main fork first
+var relationForks = []string{"", "_fsm", "_vm"}
+
+// getFileDirtyStats returns dirty and writeback pages of a file. Page flags
+// are used when available, cachestat otherwise
+func (p *PgPageCache) getFileDirtyStats(fullPath string) (d pagecache.DirtyStats, err error) {
+	if p.pageCacheState.CanReadPageFlags {
+		var pageStats pagecache.PageStats
+		pageStats, err = p.pageCacheState.GetPageCacheInfo(fullPath, p.pageSize)
+		d = pageStats.GetDirtyStats()
+	} else {
+		d, err = pagecache.GetFileDirtyStats(fullPath)
+	}
+	if err != nil {
+		return d, fmt.Errorf("dirty pages need page flags or cachestat (Linux 6.5+): %v", err)
+	}
+	return d, nil
+}
+
+// getWalPaths returns the files of the WAL directory
+func (p *PgPageCache) getWalPaths() (paths []string, err error) {
+	baseDir := p.resolveLink(path.Join(p.PgData, p.version.WalDir()))
+	entries, err := os.ReadDir(baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("Error listing file: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() {
+			paths = append(paths, path.Join(baseDir, entry.Name()))
+		}
+	}
+	return
+}
+
+// getDirtyInfos returns dirty and writeback pages of relations with all their
+// forks, sorted by dirty and writeback pages, followed by WAL
+func (p *PgPageCache) getDirtyInfos() (dirtyInfos []relation.DirtyInfo, err error) {
+	for _, relinfo := range p.getRelInfos() {
+		d := relation.DirtyInfo{Name: relinfo.Name, Kind: relinfo.Kind}
+		for _, fork := range relationForks {
+			if fork == "" && p.pageCacheState.CanReadPageFlags {
+				// Page flags of the main fork were read by the scan
+				d.Add(relinfo.GetDirtyStats())
+				continue
+			}
+			err = p.forEachSegment(relinfo.Relfilenode, fork, func(_ int64, fullPath string) error {
+				segmentDirty, err := p.getFileDirtyStats(fullPath)
+				d.Add(segmentDirty)
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if d.Dirty+d.Writeback > 0 {
+			dirtyInfos = append(dirtyInfos, d)
+		}
+	}
+	slices.SortFunc(dirtyInfos, func(a, b relation.DirtyInfo) int {
+		return cmp.Or(cmp.Compare(b.Dirty+b.Writeback, a.Dirty+a.Writeback), cmp.Compare(a.Name, b.Name))
+	})
+
+	wal := relation.DirtyInfo{Name: "WAL", Kind: 'W'}
+	if p.pageCacheState.CanReadPageFlags && p.ScanWal {
+		for _, walInfo := range p.walInfos {
+			wal.Add(walInfo.GetDirtyStats())
+		}
+	} else {
+		walPaths, err := p.getWalPaths()
+		if err != nil {
+			return nil, err
+		}
+		for _, walPath := range walPaths {
+			segmentDirty, err := p.getFileDirtyStats(walPath)
+			if err != nil {
+				return nil, err
+			}
+			wal.Add(segmentDirty)
+		}
+	}
+	return append(dirtyInfos, wal), nil
+}
+
+// getDirtySummary returns the checkpoint forecast with the kernel and server settings
+func (p *PgPageCache) getDirtySummary(ctx context.Context, relationDirty pagecache.DirtyStats) (summary []string) {
+	forecast := relationDirty.Dirty + relationDirty.Writeback
+	forecastLine := fmt.Sprintf("Checkpoint forecast: %s dirty in page cache", p.formatPages(forecast))
+	if p.conn != nil {
+		checkpoint, err := relation.GetCheckpointSettings(ctx, p.conn)
+		if err != nil {
+			slog.Warn("Couldn't get checkpoint settings", "error", err)
+		}
+		settings, settingsErr := relation.GetBufferSettings(ctx, p.conn)
+		if err == nil && settingsErr == nil && checkpoint.DirtyBuffers != nil {
+			dirtyBufferPages := int(*checkpoint.DirtyBuffers * settings.BlockSize / p.pageSize)
+			forecast += dirtyBufferPages
+			forecastLine += fmt.Sprintf(" + %s dirty shared buffers", p.formatPages(dirtyBufferPages))
+		}
+		forecastLine += fmt.Sprintf(" = %s", p.formatPages(forecast))
+		if err == nil {
+			nextCheckpoint := "unknown"
+			if checkpoint.NextCheckpoint != nil {
+				nextCheckpoint = checkpoint.NextCheckpoint.Round(1e9).String()
+			}
+			summary = append(summary, forecastLine, fmt.Sprintf("Next timed checkpoint in %s (checkpoint_timeout %s, max_wal_size %s, checkpoint_completion_target %.2f)",
+				nextCheckpoint, checkpoint.Timeout, checkpoint.MaxWalSize, checkpoint.CompletionTarget))
+		}
+	}
+	if len(summary) == 0 {
+		summary = append(summary, forecastLine)
+	}
+
+	dirtyState, err := memory.GetDirtyState(p.TargetPid)
+	if err != nil {
+		slog.Warn("Couldn't get kernel dirty memory", "error", err)
+		return
+	}
+	kbToPages := func(kb int64) string { return p.formatPages(int(kb * 1024 / p.pageSize)) }
+	kernelLine := fmt.Sprintf("Kernel dirty: %s dirty, %s writeback (%s), dirty_background threshold %s, dirty threshold %s, expire %.0fs",
+		kbToPages(dirtyState.Dirty), kbToPages(dirtyState.Writeback), dirtyState.Source,
+		kbToPages(dirtyState.BackgroundThreshold), kbToPages(dirtyState.Threshold), dirtyState.ExpireSecs)
+	switch {
+	case dirtyState.Threshold > 0 && dirtyState.Dirty >= dirtyState.Threshold:
+		kernelLine += ", writers are throttled"
+	case dirtyState.BackgroundThreshold > 0 && dirtyState.Dirty >= dirtyState.BackgroundThreshold:
+		kernelLine += ", background writeback is active"
+	}
+	return append(summary, kernelLine)
+}
+
+// outputDirtyReport outputs dirty and writeback pages of relations and WAL
+// with a forecast of the next checkpoint writes
+func (p *PgPageCache) outputDirtyReport(ctx context.Context) error {
+	dirtyInfos, err := p.getDirtyInfos()
+	if err != nil {
+		return err
+	}
+
+	var relationDirty, total pagecache.DirtyStats
+	for _, d := range dirtyInfos {
+		total.Add(d.DirtyStats)
+		if d.Kind != 'W' {
+			relationDirty.Add(d.DirtyStats)
+		}
+	}
+	totalInfo := relation.DirtyInfo{DirtyStats: total, Name: "Total", Kind: 'S'}
+	totalDirty := total.Dirty + total.Writeback
+
+	var values [][]string
+	for i := range dirtyInfos {
+		if p.Limit > 0 && i >= p.Limit && dirtyInfos[i].Kind != 'W' {
+			continue
+		}
+		values = append(values, dirtyInfos[i].ToStringArray(p.Unit, p.pageSize, totalDirty))
+	}
+	values = append(values, totalInfo.ToStringArray(p.Unit, p.pageSize, totalDirty))
+
+	err = p.outputReport(dirtyHeader, values)
+	if err != nil {
+		return err
+	}
+	summary := p.getDirtySummary(ctx, relationDirty)
+	if p.Type == FormatColumn {
+		fmt.Printf("\n%s\n", strings.Join(summary, "\n"))
+	} else {
+		for _, line := range summary {
+			slog.Info(line)
+		}
+	}
+	return nil
+}
@@ -1,35 +1,86 @@
 package app
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
+	"slices"
 	"strings"
 	"text/tabwriter"
 
 	"github.com/bonnefoa/pg_pagecache/relation"
+	"github.com/bonnefoa/pg_pagecache/utils"
 )
 
 var (
 	pageHeader = []string{
 		"Partition", "Table", "Relation", "Relfilenode", "Kind", "PageCached",
-		"PageCount", "%Cached", "%Total"}
+		"PageCount", "%Cached", "%Total", "%Drift"}
 	flagHeader = []string{"Relation", "Page Count", "Flags", "Symbolic Flags",
 		"Long Symbolic Flags"}
+	tempHeader    = []string{"PID", "Relation", "Kind", "PageCached", "Query"}
+	rewriteHeader = []string{"Relation", "Oid", "Old Relfilenode", "New Relfilenode",
+		"PageCached Lost", "Since"}
+	wasteHeader = []string{"Relation", "Kind", "Oid", "PageCached", "Scans",
+		"Stats Reset", "Action"}
+	statioHeader = []string{"Relation", "Kind", "Shared Hits", "Reads", "%Shared Hit",
+		"%OS Cached", "Est. OS Hits", "Est. Disk Reads", "Avg Read ms", "Advice"}
+	impactHeader = []string{"Relation", "Relfilenode", "Kind", "Before", "After",
+		"Pulled In", "Evicted"}
+	doubleBufferHeader = []string{"Relation", "Relfilenode", "Both", "Shared Buffers Only",
+		"OS Only", "%Double", "%Total"}
 )
 
-func (p *PgPageCache) outputColumns(values [][]string, outputInfos []relation.OutputInfo) {
+// formatPages formats a number of os pages in the requested unit
+func (p *PgPageCache) formatPages(pages int) string {
+	return utils.FormatPageValue(pages, p.Unit, p.pageSize)
+}
+
+func (p *PgPageCache) outputColumns(values [][]string, lines []outputLine) {
 	w := tabwriter.NewWriter(os.Stdout, 14, 0, 1, ' ', 0)
 	for _, v := range values {
 		fmt.Fprintln(w, strings.Join(v, "\t"))
 	}
 	w.Flush()
+	fmt.Printf("\n%%Total of %s (%s)\n", p.formatPages(int(p.fileMemory*1024/p.pageSize)), p.memorySource)
+	if p.FullWalk {
+		fmt.Println(p.getCoverage())
+	}
+
+	if len(p.tempInfos) > 0 {
+		fmt.Printf("\nTemporary Files\n")
+		fmt.Fprintln(w, strings.Join(tempHeader, "\t"))
+		for _, v := range p.tempInfos {
+			fmt.Fprintln(w, strings.Join(v.ToTempDetails(p.Unit, p.pageSize), "\t"))
+		}
+		w.Flush()
+	}
+
+	if len(p.rewrites) > 0 {
+		fmt.Printf("\nRewritten Relations\n")
+		fmt.Fprintln(w, strings.Join(rewriteHeader, "\t"))
+		for _, v := range p.rewrites {
+			fmt.Fprintln(w, strings.Join(v.ToStringArray(p.Unit, p.pageSize), "\t"))
+		}
+		w.Flush()
+	}
+
+	if len(p.doubleBuffers) > 0 {
+		fmt.Printf("\nDouble Buffering\n")
+		fmt.Fprintln(w, strings.Join(doubleBufferHeader, "\t"))
+		for _, v := range p.getDoubleBufferValues() {
+			fmt.Fprintln(w, strings.Join(v, "\t"))
+		}
+		w.Flush()
+	}
 
 	if p.pageCacheState.CanReadPageFlags && !p.GroupTable {
 		fmt.Printf("\nPage Flags\n")
 		fmt.Fprintln(w, strings.Join(flagHeader, "\t"))
-		for _, v := range outputInfos {
+		for _, v := range lines {
 			for _, flag := range v.ToFlagDetails() {
 				fmt.Fprintln(w, strings.Join(flag, "\t"))
 			}
@@ -39,6 +90,11 @@ func (p *PgPageCache) outputColumns(values [][]string, outputInfos []relation.Ou
 }
 
 func (p *PgPageCache) outputJSON(header []string, values [][]string) error {
+	return printJSON(jsonRows(header, values))
+}
+
+// jsonRows converts lines to objects keyed by the header columns
+func jsonRows(header []string, values [][]string) []map[string]string {
 	m := make([]map[string]string, 0)
 	for _, line := range values {
 		o := make(map[string]string, 0)
@@ -47,7 +103,11 @@ func (p *PgPageCache) outputJSON(header []string, values [][]string) error {
 		}
 		m = append(m, o)
 	}
-	res, err := json.Marshal(m)
+	return m
+}
+
+func printJSON(v any) error {
+	res, err := json.Marshal(v)
 	if err != nil {
 		return err
 	}
@@ -56,38 +116,163 @@ func (p *PgPageCache) outputJSON(header []string, values [][]string) error {
 }
 
 // AdjustLine remove unecessary output lines
+// Lines start with the displayed dimensions, followed by the page header columns
+// Partition is hidden when there's no partition
 // When grouping table, relation and relfilenode will always be empty
+// Stats drift is only displayed when requested
 func (p *PgPageCache) AdjustLine(line []string) []string {
 	var res []string
 	_, hasNoPartition := p.partitions[relation.NoPartition]
-	if len(p.partitions) == 1 && hasNoPartition {
-		// table
-		res = append(res, line[1:2]...)
-	} else {
-		// Partition + table
-		res = append(res, line[0:2]...)
+	dimensions := p.displayDimensions()
+	for i, d := range dimensions {
+		if d == DimensionPartition && len(p.partitions) == 1 && hasNoPartition {
+			continue
+		}
+		res = append(res, line[i])
 	}
+	line = line[len(dimensions):]
 	if !p.GroupTable {
 		// Relation + relfilenode
-		res = append(res, line[2:4]...)
+		res = append(res, line[0:2]...)
+	}
+	if p.StatsDrift {
+		res = append(res, line[2:]...)
+	} else {
+		res = append(res, line[2:len(line)-1]...)
 	}
-	res = append(res, line[4:]...)
 	return res
 }
 
-func (p *PgPageCache) outputResults(outputInfos []relation.OutputInfo) error {
+// getHeader returns the dimension columns followed by the page header, without
+// partition and table which are provided by dimensions
+func (p *PgPageCache) getHeader() []string {
+	var header []string
+	for _, d := range p.displayDimensions() {
+		header = append(header, p.dimensionHeader(d))
+	}
+	return append(header, pageHeader[2:]...)
+}
+
+func (p *PgPageCache) outputResults(lines []outputLine) error {
 	var values [][]string
 
-	header := p.AdjustLine(pageHeader)
+	header := p.AdjustLine(p.getHeader())
 	if !p.NoHeader && p.Type != FormatJSON {
 		values = append(values, header)
 	}
 
-	for _, v := range outputInfos {
-		line := v.ToStringArray(p.Unit, p.pageSize, p.fileMemory)
+	for _, v := range lines {
+		// Partition and table are replaced by the dimension values
+		line := append(slices.Clone(v.dimensions), v.ToStringArray(p.Unit, p.pageSize, p.fileMemory)[2:]...)
 		values = append(values, p.AdjustLine(line))
 	}
 
+	if p.Type != FormatColumn {
+		slog.Info("%Total denominator", "cache_memory", p.formatPages(int(p.fileMemory*1024/p.pageSize)), "source", p.memorySource)
+	}
+
+	switch p.Type {
+	case FormatCSV:
+		w := csv.NewWriter(os.Stdout)
+		w.WriteAll(values)
+		if err := w.Error(); err != nil {
+			return err
+		}
+		if len(p.doubleBuffers) > 0 {
+			return p.writeDoubleBufferingFile()
+		}
+	case FormatJSON:
+		if len(p.doubleBuffers) == 0 {
+			return p.outputJSON(header, values)
+		}
+		// Relations and double buffering are kept in a single document
+		return printJSON(struct {
+			Relations       []map[string]string `json:"relations"`
+			DoubleBuffering []map[string]string `json:"double_buffering"`
+		}{
+			Relations:       jsonRows(header, values),
+			DoubleBuffering: jsonRows(doubleBufferHeader, p.getDoubleBufferValues()),
+		})
+	case FormatColumn:
+		p.outputColumns(values, lines)
+	}
+	return nil
+}
+
+// writeDoubleBufferingFile writes the double buffering section as csv to
+// the -double_buffering_file, keeping stdout a single csv table
+func (p *PgPageCache) writeDoubleBufferingFile() error {
+	f, err := os.Create(p.DoubleBufferingFile)
+	if err != nil {
+		return fmt.Errorf("Error creating double buffering file: %v", err)
+	}
+	defer f.Close()
+
+	values := p.getDoubleBufferValues()
+	if !p.NoHeader {
+		values = append([][]string{doubleBufferHeader}, values...)
+	}
+	w := csv.NewWriter(f)
+	w.WriteAll(values)
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("Error writing double buffering file: %v", err)
+	}
+	slog.Info("Double buffering written", "file", p.DoubleBufferingFile, "relations", len(values))
+	return nil
+}
+
+// outputWasteReport outputs cached relations with no or minimal scans since the last stats reset
+func (p *PgPageCache) outputWasteReport(ctx context.Context) error {
+	if p.conn == nil {
+		return fmt.Errorf("waste report needs a connection to read access stats")
+	}
+	accessStats, statsReset, err := relation.GetAccessStats(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	wasteInfos := relation.GetWasteInfos(p.getRelInfos(), accessStats, statsReset, p.WasteScanThreshold)
+
+	var values [][]string
+	for i := range wasteInfos {
+		if p.Limit > 0 && i >= p.Limit {
+			break
+		}
+		values = append(values, wasteInfos[i].ToStringArray(p.Unit, p.pageSize))
+	}
+	return p.outputReport(wasteHeader, values)
+}
+
+// outputStatioReport outputs shared buffers hits and reads of relations with
+// reads estimated to be served by the page cache
+func (p *PgPageCache) outputStatioReport(ctx context.Context) error {
+	if p.conn == nil {
+		return fmt.Errorf("statio report needs a connection to read statio stats")
+	}
+	statioStats, ioTiming, err := relation.GetStatioStats(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	if !ioTiming.Enabled {
+		slog.Info("track_io_timing is disabled, read time won't be available")
+	}
+	statioInfos := relation.GetStatioInfos(p.getRelInfos(), statioStats)
+
+	var values [][]string
+	for i := range statioInfos {
+		if p.Limit > 0 && i >= p.Limit {
+			break
+		}
+		values = append(values, statioInfos[i].ToStringArray(ioTiming))
+	}
+	return p.outputReport(statioHeader, values)
+}
+
+// outputReport outputs the values of a report mode in the requested format
+func (p *PgPageCache) outputReport(header []string, values [][]string) error {
+	if !p.NoHeader && p.Type != FormatJSON {
+		values = append([][]string{header}, values...)
+	}
+
 	switch p.Type {
 	case FormatCSV:
 		w := csv.NewWriter(os.Stdout)
@@ -96,7 +281,11 @@ func (p *PgPageCache) outputResults(outputInfos []relation.OutputInfo) error {
 	case FormatJSON:
 		return p.outputJSON(header, values)
 	case FormatColumn:
-		p.outputColumns(values, outputInfos)
+		w := tabwriter.NewWriter(os.Stdout, 14, 0, 1, ' ', 0)
+		for _, v := range values {
+			fmt.Fprintln(w, strings.Join(v, "\t"))
+		}
+		w.Flush()
 	}
 	return nil
 }
@@ -3,6 +3,7 @@ package app
 import (
 	"flag"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/bonnefoa/pg_pagecache/utils"
@@ -14,6 +15,9 @@ type FormatSort int
 // FormatType represents the different type options
 type FormatType int
 
+// Dimension represents a relation attribute used to aggregate results
+type Dimension int
+
 // FormatFlags stores all format related flags
 type FormatFlags struct {
 	Unit           utils.Unit
@@ -23,6 +27,8 @@ type FormatFlags struct {
 	NoHeader       bool
 	GroupTable     bool
 	GroupPartition bool
+	StatsDrift     bool
+	Aggregation    []Dimension
 }
 
 const (
@@ -41,6 +47,29 @@ const (
 	FormatJSON
 )
 
+const (
+	// DimensionDatabase aggregates by database
+	DimensionDatabase Dimension = iota
+	// DimensionSchema aggregates by schema
+	DimensionSchema
+	// DimensionTablespace aggregates by tablespace
+	DimensionTablespace
+	// DimensionOwner aggregates by owner role
+	DimensionOwner
+	// DimensionAccessMethod aggregates by access method
+	DimensionAccessMethod
+	// DimensionRelkind aggregates by relkind
+	DimensionRelkind
+	// DimensionPartitionRoot aggregates by top-most partitioned table
+	DimensionPartitionRoot
+	// DimensionPartition aggregates by direct parent partition
+	DimensionPartition
+	// DimensionTable aggregates indexes and toast with their owning table
+	DimensionTable
+	// DimensionContainer labels results with the container of -target_pid
+	DimensionContainer
+)
+
 var (
 	formatSortMap = map[string]FormatSort{
 		"name":       SortName,
@@ -61,6 +90,19 @@ var (
 		"json":   FormatJSON,
 	}
 
+	dimensionMap = map[string]Dimension{
+		"database":       DimensionDatabase,
+		"schema":         DimensionSchema,
+		"tablespace":     DimensionTablespace,
+		"owner":          DimensionOwner,
+		"access_method":  DimensionAccessMethod,
+		"relkind":        DimensionRelkind,
+		"partition_root": DimensionPartitionRoot,
+		"partition":      DimensionPartition,
+		"table":          DimensionTable,
+		"container":      DimensionContainer,
+	}
+
 	formatFlags     FormatFlags
 	typeFlag        string
 	unitFlag        string
@@ -72,11 +114,12 @@ func init() {
 	flag.IntVar(&formatFlags.Limit, "limit", -1, "Maximum number of results to format. -1 to format everything.")
 	flag.BoolVar(&formatFlags.NoHeader, "no_header", false, "Don't print header.")
 	flag.BoolVar(&formatFlags.GroupPartition, "group_partition", false, "Group partition.")
-	flag.BoolVar(&formatFlags.GroupTable, "group_table", false, "Group indexes, toast with owning relation.")
+	flag.BoolVar(&formatFlags.GroupTable, "group_table", false, "Group indexes, toast with owning relation. Relations are not displayed under aggregations.")
+	flag.BoolVar(&formatFlags.StatsDrift, "stats_drift", false, "Display the difference between pg_class.relpages and the on-disk size.")
 	flag.StringVar(&typeFlag, "format", "column", "Output format to use. Can be csv, column or json")
 	flag.StringVar(&unitFlag, "unit", "mb", "Unit to use for paeg count and page cached. Can be page, kb, mb or gb")
 	flag.StringVar(&sortFlag, "sort", "pagecached", "Field to use for sort. Can be relation, pagecount or pagecached")
-	flag.StringVar(&aggregationFlag, "aggregation", "none", "How to aggregate results. relation, table, table_only")
+	flag.StringVar(&aggregationFlag, "aggregation", "none", "Dimensions to aggregate results, separated with commas and nested in order. Can be none, database, schema, tablespace, owner, access_method, relkind, partition_root, partition, table or container")
 }
 
 func parseSort(s string) (FormatSort, error) {
@@ -106,6 +149,23 @@ func parseTypeFlag(s string) (FormatType, error) {
 	return res, nil
 }
 
+func parseAggregationFlag(s string) (dimensions []Dimension, err error) {
+	if s == "" || strings.ToLower(s) == "none" {
+		return nil, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		dimension, ok := dimensionMap[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("unknown aggregation: %v", name)
+		}
+		if slices.Contains(dimensions, dimension) {
+			return nil, fmt.Errorf("duplicated aggregation: %v", name)
+		}
+		dimensions = append(dimensions, dimension)
+	}
+	return
+}
+
 // ParseFormatOptions parses and return FormatFlags with parsed values
 func ParseFormatOptions() (FormatFlags, error) {
 	var err error
@@ -121,5 +181,17 @@ func ParseFormatOptions() (FormatFlags, error) {
 	if err != nil {
 		return formatFlags, err
 	}
+	formatFlags.Aggregation, err = parseAggregationFlag(aggregationFlag)
+	if err != nil {
+		return formatFlags, err
+	}
+	if len(formatFlags.Aggregation) == 0 {
+		// group_partition and group_table are shortcuts for aggregations
+		if formatFlags.GroupPartition {
+			formatFlags.Aggregation = []Dimension{DimensionPartition, DimensionTable}
+		} else if formatFlags.GroupTable {
+			formatFlags.Aggregation = []Dimension{DimensionTable}
+		}
+	}
 	return formatFlags, err
 }
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+var freeSpaceHeader = []string{"Relation", "Kind", "PageCached", "Effective Data", "Free Space", "Overhead", "%Free"}
+
+// getFreeSpaceInfos returns the free space of cached heap blocks of each table, sorted by free space
+func (p *PgPageCache) getFreeSpaceInfos() (freeSpaceInfos []relation.FreeSpaceInfo, err error) {
+	blockSize := p.bufferSettings.BlockSize
+	for _, relinfo := range p.getRelInfos() {
+		if relinfo.PageCached == 0 || !relation.IsHeapKind(relinfo.Kind) {
+			continue
+		}
+		f := relation.FreeSpaceInfo{Name: relinfo.Name, Kind: relinfo.Kind, PageCached: relinfo.PageCached}
+		err = p.forEachCachedBlock(relinfo.Relfilenode, func(blockno int64, block []byte) error {
+			header, err := pgdisk.ParsePageHeader(block)
+			if err != nil {
+				return err
+			}
+			if !header.IsNew() && !header.IsValid(int(blockSize)) {
+				slog.Debug("Skipping invalid block", "relation", relinfo.Name, "block", blockno)
+				return nil
+			}
+			f.AddBlock(header.Lower, header.Upper, header.Special, blockSize)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if f.CachedBlocks > 0 {
+			freeSpaceInfos = append(freeSpaceInfos, f)
+		}
+	}
+	slices.SortFunc(freeSpaceInfos, func(a, b relation.FreeSpaceInfo) int {
+		return cmp.Or(cmp.Compare(b.Free, a.Free), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// outputFreeSpaceReport outputs how much of the cached heap blocks is tuple data or empty space
+func (p *PgPageCache) outputFreeSpaceReport(ctx context.Context) error {
+	err := p.loadBufferSettings(ctx)
+	if err != nil {
+		return err
+	}
+	freeSpaceInfos, err := p.getFreeSpaceInfos()
+	if err != nil {
+		return err
+	}
+
+	total := relation.FreeSpaceInfo{Name: "Total", Kind: 'S'}
+	var values [][]string
+	for i := range freeSpaceInfos {
+		total.Add(freeSpaceInfos[i])
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, freeSpaceInfos[i].ToStringArray(p.Unit, p.pageSize))
+	}
+	values = append(values, total.ToStringArray(p.Unit, p.pageSize))
+
+	err = p.outputReport(freeSpaceHeader, values)
+	if err != nil {
+		return err
+	}
+
+	freeSpace := utils.FormatKBValue(total.Free/1024, utils.UnitMB)
+	if p.Type == FormatColumn {
+		fmt.Printf("\nEmpty space in cached heap blocks: %s (%s%%)\n", freeSpace, total.GetFreePct())
+	} else {
+		slog.Info("Empty space in cached heap blocks", "free_space", freeSpace, "free_pct", total.GetFreePct())
+	}
+	return nil
+}
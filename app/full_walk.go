@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+var (
+	// relationFileRegex matches relation files with their fork and segment
+	relationFileRegex = regexp.MustCompile(`^(\d+)(?:_(?:fsm|vm|init))?(?:\.\d+)?$`)
+
+	// databaseDirFiles are the non relation files of a database directory
+	databaseDirFiles = []string{"pg_filenode.map", "pg_internal.init", "PG_VERSION"}
+
+	// knownDirs are the pgdata directories not holding relations
+	knownDirs = []string{"global", "pg_multixact", "pg_subtrans", "pg_commit_ts", "pg_stat",
+		"pg_stat_tmp", "pg_logical", "pg_replslot", "pg_snapshots", "pg_serial",
+		"pg_notify", "pg_dynshmem", "pg_twophase"}
+)
+
+// getKnownRelfilenodes returns relfilenodes of the scanned database's relations.
+// When connected, relations not fetched by the catalog query, like sequences
+// or rewritten mapped catalogs, are added
+func (p *PgPageCache) getKnownRelfilenodes(ctx context.Context) (map[uint32]bool, error) {
+	known := make(map[uint32]bool, 0)
+	for _, relinfo := range p.getRelInfos() {
+		known[relinfo.Relfilenode] = true
+	}
+	if p.conn == nil {
+		return known, nil
+	}
+	relfilenodes, err := relation.GetStorageRelfilenodes(ctx, p.conn)
+	if err != nil {
+		return nil, err
+	}
+	for _, relfilenode := range relfilenodes {
+		known[relfilenode] = true
+	}
+	return known, nil
+}
+
+// isDatabaseFile checks if a file of a database directory belongs to
+// postgres. Relation files of the scanned database need a known relfilenode
+func (p *PgPageCache) isDatabaseFile(dbDir string, name string) bool {
+	if slices.Contains(databaseDirFiles, name) || tempRelationRegex.MatchString(name) {
+		return true
+	}
+	res := relationFileRegex.FindStringSubmatch(name)
+	if res == nil {
+		return false
+	}
+	if dbDir != fmt.Sprintf("%d", p.dbid) || len(p.Relations) > 0 {
+		// Only relations of the scanned database are known
+		return true
+	}
+	relfilenode, _ := strconv.ParseUint(res[1], 10, 32)
+	return p.knownRelfilenodes[uint32(relfilenode)]
+}
+
+// isAttributed checks if a file, relative to pgdata, belongs to a relation
+// or a known category
+func (p *PgPageCache) isAttributed(rel string, logDir string) bool {
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) == 1 {
+		// Configuration, control and pid files
+		return true
+	}
+	// Tablespaces have a version directory before the database directories
+	if parts[0] == "pg_tblspc" && len(parts) > 3 {
+		parts = parts[2:]
+	} else if parts[0] != "base" {
+		return parts[0] == p.version.WalDir() || parts[0] == p.version.XactDir() ||
+			parts[0] == logDir || slices.Contains(knownDirs, parts[0])
+	}
+	switch {
+	case len(parts) > 2 && parts[1] == "pgsql_tmp":
+		return true
+	case len(parts) == 3:
+		return p.isDatabaseFile(parts[1], parts[2])
+	}
+	return false
+}
+
+// walkFiles calls fn with the page stats of all regular files under root,
+// following symlinks like pg_wal or tablespaces
+func (p *PgPageCache) walkFiles(root string, relPrefix string, fn func(rel string, pageStats pagecache.PageStats)) error {
+	return filepath.WalkDir(root, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			slog.Debug("Skipping path", "path", fullPath, "error", err)
+			return nil
+		}
+		rel, err := filepath.Rel(root, fullPath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.Join(relPrefix, rel)
+		if d.Type()&fs.ModeSymlink != 0 {
+			return p.walkFiles(p.resolveLink(fullPath), rel, fn)
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		pageStats, err := p.pageCacheState.GetPageCacheInfo(fullPath, p.pageSize)
+		if err != nil {
+			// Files can be removed at any time
+			slog.Debug("Skipping file", "path", fullPath, "error", err)
+			return nil
+		}
+		fn(rel, pageStats)
+		return nil
+	})
+}
+
+// walkPgData fetches page cache usage of every file under pgdata and
+// tablespaces, returning the total and the unattributed files' usage
+func (p *PgPageCache) walkPgData(ctx context.Context) (total pagecache.PageStats, unattributed relation.BaseInfo, err error) {
+	unattributed = relation.BaseInfo{Name: "Unattributed", Kind: 'U'}
+	logDir := path.Base(p.getLogDir(ctx))
+	err = p.walkFiles(p.PgData, "", func(rel string, pageStats pagecache.PageStats) {
+		total.Add(pageStats)
+		if !p.isAttributed(rel, logDir) {
+			slog.Debug("Unattributed file", "path", rel, "pageCached", pageStats.PageCached)
+			unattributed.Add(pageStats)
+		}
+	})
+	if err != nil {
+		return total, unattributed, fmt.Errorf("Error walking pgdata: %v", err)
+	}
+	return
+}
+
+// getCoverage returns the coverage line, comparing cached pages of pgdata
+// files with the cached memory
+func (p *PgPageCache) getCoverage() string {
+	return fmt.Sprintf("Coverage: pgdata files hold %s of %s cached memory (%s%%), %s unattributed",
+		p.formatPages(p.pgDataStats.PageCached), p.formatPages(int(p.fileMemory*1024/p.pageSize)),
+		p.pgDataStats.GetTotalCachedPct(p.pageSize, p.fileMemory),
+		p.formatPages(p.unattributed.PageCached))
+}
@@ -0,0 +1,177 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// residencySnapshot stores the page residency of relations, indexed by relfilenode
+type residencySnapshot map[uint32][]bool
+
+// getInvolvedRelInfos returns the relations of tables referenced by the
+// query's plan, with their indexes and toast as writes also touch them.
+// Without relation in the plan, like a function call, all scanned relations are used
+func (p *PgPageCache) getInvolvedRelInfos(ctx context.Context) ([]relation.RelInfo, error) {
+	planRelations, err := relation.GetPlanRelations(ctx, p.conn, p.Query)
+	if err != nil {
+		return nil, err
+	}
+	if len(planRelations) == 0 {
+		slog.Warn("No relation found in the query plan, snapshotting all scanned relations")
+		return p.getRelInfos(), nil
+	}
+
+	var relinfos []relation.RelInfo
+	for _, partInfo := range p.partitions {
+		for tableName, tableInfo := range partInfo.TableInfos {
+			involved := planRelations[tableName]
+			for _, relinfo := range tableInfo.RelInfos {
+				involved = involved || planRelations[relinfo.Name]
+			}
+			if involved {
+				relinfos = append(relinfos, tableInfo.RelInfos...)
+			}
+		}
+	}
+	slog.Info("Relations involved by the query", "planRelations", len(planRelations), "relations", len(relinfos))
+	return relinfos, nil
+}
+
+// snapshotResidency reads the page residency of the provided relations
+func (p *PgPageCache) snapshotResidency(relinfos []relation.RelInfo, settings relation.BufferSettings) (snapshot residencySnapshot, err error) {
+	snapshot = make(residencySnapshot, 0)
+	for _, relinfo := range relinfos {
+		snapshot[relinfo.Relfilenode], err = p.getBlockResidency(relinfo.Relfilenode, settings)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return snapshot, nil
+}
+
+// runQuery runs the impact query in a rolled back transaction, returning the
+// plan when explain is requested
+func (p *PgPageCache) runQuery(ctx context.Context) (plan []string, err error) {
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if !p.Explain {
+		_, err = tx.Exec(ctx, p.Query)
+		if err != nil {
+			return nil, fmt.Errorf("error running query: %v", err)
+		}
+		return nil, nil
+	}
+
+	rows, err := tx.Query(ctx, "EXPLAIN (ANALYZE, BUFFERS) "+p.Query)
+	if err != nil {
+		return nil, fmt.Errorf("error running query: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var line string
+		err = rows.Scan(&line)
+		if err != nil {
+			return nil, fmt.Errorf("error reading plan: %v", err)
+		}
+		plan = append(plan, line)
+	}
+	return plan, rows.Err()
+}
+
+// getImpactInfos compares page residency of relations before and after the query
+func (p *PgPageCache) getImpactInfos(relinfos []relation.RelInfo, before residencySnapshot, after residencySnapshot) (impactInfos []relation.ImpactInfo) {
+	for _, relinfo := range relinfos {
+		impact := relation.ImpactInfo{Name: relinfo.Name, Relfilenode: relinfo.Relfilenode, Kind: relinfo.Kind}
+		beforePages := before[relinfo.Relfilenode]
+		afterPages := after[relinfo.Relfilenode]
+		for i, resident := range afterPages {
+			wasResident := i < len(beforePages) && beforePages[i]
+			if resident {
+				impact.After++
+			}
+			if resident && !wasResident {
+				impact.PulledIn++
+			}
+		}
+		for i, wasResident := range beforePages {
+			if !wasResident {
+				continue
+			}
+			impact.Before++
+			if i >= len(afterPages) || !afterPages[i] {
+				impact.Evicted++
+			}
+		}
+		if impact.PulledIn > 0 || impact.Evicted > 0 {
+			impactInfos = append(impactInfos, impact)
+		}
+	}
+	slices.SortFunc(impactInfos, func(a, b relation.ImpactInfo) int {
+		return cmp.Or(cmp.Compare(b.PulledIn, a.PulledIn), cmp.Compare(b.Evicted, a.Evicted), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// runImpact snapshots page residency of relations before and after running
+// the query and reports pages pulled into or evicted from the page cache
+func (p *PgPageCache) runImpact(ctx context.Context) error {
+	if p.conn == nil {
+		return fmt.Errorf("impact command needs a connection to run the query")
+	}
+	bufferSettings, err := relation.GetBufferSettings(ctx, p.conn)
+	if err != nil {
+		return err
+	}
+	// Use os pages as blocks to keep the page residency
+	settings := relation.BufferSettings{
+		BlockSize:     p.pageSize,
+		SegmentBlocks: bufferSettings.SegmentBlocks * bufferSettings.BlockSize / p.pageSize,
+	}
+	relinfos, err := p.getInvolvedRelInfos(ctx)
+	if err != nil {
+		return err
+	}
+	before, err := p.snapshotResidency(relinfos, settings)
+	if err != nil {
+		return err
+	}
+	plan, err := p.runQuery(ctx)
+	if err != nil {
+		return err
+	}
+	after, err := p.snapshotResidency(relinfos, settings)
+	if err != nil {
+		return err
+	}
+
+	impactInfos := p.getImpactInfos(relinfos, before, after)
+	total := relation.ImpactInfo{Name: "Total", Kind: 'S'}
+	var values [][]string
+	for i := range impactInfos {
+		total.Add(impactInfos[i])
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, impactInfos[i].ToStringArray(p.Unit, p.pageSize))
+	}
+	values = append(values, total.ToStringArray(p.Unit, p.pageSize))
+
+	if len(plan) > 0 {
+		if p.Type == FormatColumn {
+			fmt.Printf("%s\n\n", strings.Join(plan, "\n"))
+		} else {
+			slog.Info("Query plan", "plan", strings.Join(plan, "\n"))
+		}
+	}
+	return p.outputReport(impactHeader, values)
+}
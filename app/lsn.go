@@ -0,0 +1,102 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+// getLsnInfos returns the LSN age distribution of the cached blocks of each relation
+func (p *PgPageCache) getLsnInfos(positions relation.LsnPositions) (lsnInfos []relation.LsnInfo, err error) {
+	for _, relinfo := range p.getRelInfos() {
+		if relinfo.PageCached == 0 {
+			continue
+		}
+		l := relation.NewLsnInfo(relinfo.Name, relinfo.Kind)
+		err = p.forEachCachedBlock(relinfo.Relfilenode, func(_ int64, block []byte) error {
+			header, err := pgdisk.ParsePageHeader(block)
+			if err != nil || header.IsNew() {
+				// Zeroed pages were never written
+				return err
+			}
+			l.AddBlock(header.Lsn, positions)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if l.Cached > 0 {
+			lsnInfos = append(lsnInfos, l)
+		}
+	}
+	slices.SortFunc(lsnInfos, func(a, b relation.LsnInfo) int {
+		return cmp.Or(cmp.Compare(b.Cached, a.Cached), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// formatLsn formats a WAL location like pg_lsn
+func formatLsn(lsn uint64) string {
+	return fmt.Sprintf("%X/%X", lsn>>32, uint32(lsn))
+}
+
+// outputLsnReport outputs how long ago the cached blocks of each relation
+// were last modified, using their page LSN
+func (p *PgPageCache) outputLsnReport(ctx context.Context) error {
+	if p.conn == nil {
+		return fmt.Errorf("LSN report needs a connection to get the current WAL location")
+	}
+	positions, err := relation.GetLsnPositions(ctx, p.conn, p.version)
+	if err != nil {
+		return err
+	}
+	err = p.loadBufferSettings(ctx)
+	if err != nil {
+		return err
+	}
+	lsnInfos, err := p.getLsnInfos(positions)
+	if err != nil {
+		return err
+	}
+
+	total := relation.NewLsnInfo("Total", 'S')
+	var values [][]string
+	for i := range lsnInfos {
+		total.Add(lsnInfos[i])
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, lsnInfos[i].ToStringArray(p.Unit, p.bufferSettings.BlockSize, positions))
+	}
+	values = append(values, total.ToStringArray(p.Unit, p.bufferSettings.BlockSize, positions))
+
+	header := append([]string{"Relation", "Kind", "Cached"}, positions.GetLsnAgeHeaders()...)
+	err = p.outputReport(header, values)
+	if err != nil {
+		return err
+	}
+
+	if positions.Redo == nil {
+		if p.Type == FormatColumn {
+			fmt.Printf("\nCurrent WAL location %s\n", formatLsn(positions.Current))
+		} else {
+			slog.Info("WAL locations", "current", formatLsn(positions.Current))
+		}
+		return nil
+	}
+	redo := *positions.Redo
+	redoDistance := utils.FormatKBValue(int64(positions.Current-min(redo, positions.Current))/1024, utils.UnitMB)
+	if p.Type == FormatColumn {
+		fmt.Printf("\nCurrent WAL location %s, last checkpoint redo %s (%s behind)\n",
+			formatLsn(positions.Current), formatLsn(redo), redoDistance)
+	} else {
+		slog.Info("WAL locations", "current", formatLsn(positions.Current), "redo", formatLsn(redo), "redo_distance", redoDistance)
+	}
+	return nil
+}
@@ -7,11 +7,15 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"log/slog"
 
 	"github.com/bonnefoa/pg_pagecache/memory"
 	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+	"github.com/bonnefoa/pg_pagecache/postmaster"
 	"github.com/bonnefoa/pg_pagecache/relation"
 	"github.com/bonnefoa/pg_pagecache/utils"
 	"github.com/jackc/pgx/v5"
@@ -22,12 +26,27 @@ type PgPageCache struct {
 	CliArgs
 	conn *pgx.Conn
 
-	dbid           uint32
-	database       string
-	pageSize       int64
-	fileMemory     int64 // File backed memory in KB
-	partitions     map[string]relation.PartInfo
-	pageCacheState pagecache.State
+	dbid         uint32
+	database     string
+	version      pgversion.Version
+	pageSize     int64
+	fileMemory   int64 // File backed memory in KB, without shared memory
+	memorySource string
+	partitions   map[string]relation.PartInfo
+	tempInfos    []relation.TempInfo
+	walInfos     []relation.BaseInfo
+	dirInfos     []relation.BaseInfo
+
+	knownRelfilenodes map[uint32]bool
+	// scannedRelinfos are all scanned relations, before page thresholds are applied
+	scannedRelinfos []relation.RelInfo
+	pgDataStats     pagecache.PageStats
+	unattributed    relation.BaseInfo
+	rewrites        []relation.RewriteEvent
+	doubleBuffers   []relation.DoubleBufferInfo
+	bufferSettings  relation.BufferSettings
+	customGroups    bool
+	pageCacheState  pagecache.State
 }
 
 func (p *PgPageCache) fillRelinfo(relinfo *relation.RelInfo) (err error) {
@@ -38,30 +57,14 @@ func (p *PgPageCache) fillRelinfo(relinfo *relation.RelInfo) (err error) {
 		return
 	}
 
-	segno := 0
-
-	for {
-		filename := fmt.Sprintf("%d", relinfo.Relfilenode)
-		if segno > 0 {
-			filename = fmt.Sprintf("%d.%d", relinfo.Relfilenode, segno)
-		}
-		segno++
-		fullPath := filepath.Join(baseDir, filename)
-		_, err = os.Stat(fullPath)
-		if err != nil {
-			if errors.Is(err, os.ErrNotExist) && segno > 0 {
-				// Last segment was processed, exit
-				return nil
-			}
-			return
-		}
-
+	return p.forEachSegment(relinfo.Relfilenode, "", func(_ int64, fullPath string) error {
 		segmentPcStats, err := p.pageCacheState.GetPageCacheInfo(fullPath, p.pageSize)
 		if err != nil {
 			return err
 		}
 		relinfo.Add(segmentPcStats)
-	}
+		return nil
+	})
 }
 
 func (p *PgPageCache) fillTableStats(table *relation.TableInfo) error {
@@ -72,6 +75,11 @@ func (p *PgPageCache) fillTableStats(table *relation.TableInfo) error {
 		if err != nil {
 			return err
 		}
+		p.scannedRelinfos = append(p.scannedRelinfos, relinfo)
+		if relinfo.PageCount <= p.PageThreshold {
+			// Relation is too small, ignore it
+			continue
+		}
 		if relinfo.PageCached >= p.CachedPageThreshold {
 			filteredRelinfo = append(filteredRelinfo, relinfo)
 		}
@@ -82,6 +90,122 @@ func (p *PgPageCache) fillTableStats(table *relation.TableInfo) error {
 	return nil
 }
 
+// resolveLink returns the target of a symlink such as pg_wal or tablespaces,
+// resolving absolute targets in the target postmaster's mount namespace
+func (p *PgPageCache) resolveLink(linkPath string) string {
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		// Not a symlink
+		return linkPath
+	}
+	if path.IsAbs(target) {
+		return path.Join(p.RootDir, target)
+	}
+	return path.Join(path.Dir(linkPath), target)
+}
+
+// getShmem returns the shared memory to remove from the cached memory with its source.
+// Without shmem reported with cached memory, use the largest of the
+// postmaster's resident shmem and the server's shared memory size, as the
+// postmaster only maps a fraction of the buffer pool touched by backends
+func (p *PgPageCache) getShmem(ctx context.Context, cached memory.CachedMemory) (int64, string) {
+	if cached.ShmemSource != "" {
+		return cached.Shmem, cached.ShmemSource
+	}
+
+	var shmem int64
+	var source string
+	pid := p.TargetPid
+	if pid == 0 {
+		cluster, err := postmaster.ReadPidFile(p.PgData)
+		if err == nil {
+			pid = cluster.Pid
+		}
+	}
+	if pid != 0 {
+		rssShmem, err := memory.GetRssShmem(pid)
+		if err == nil && rssShmem > shmem {
+			shmem, source = rssShmem, "postmaster RssShmem"
+		}
+		if err != nil {
+			slog.Debug("Couldn't get postmaster shmem", "pid", pid, "error", err)
+		}
+	}
+
+	if p.conn != nil {
+		size, setting, err := relation.GetSharedMemorySize(ctx, p.conn, p.version)
+		if err == nil && size > shmem {
+			shmem, source = size, setting
+		}
+		if err != nil {
+			slog.Debug("Couldn't get shared memory size", "error", err)
+		}
+	}
+	return shmem, source
+}
+
+// loadCachedMemory fetches the cached memory used as %Total denominator,
+// removing shared memory when it is accounted as cached memory
+func (p *PgPageCache) loadCachedMemory(ctx context.Context) (err error) {
+	var cached memory.CachedMemory
+	if p.TargetPid != 0 {
+		// %Total is relative to the target's cgroup
+		cached, err = memory.GetProcessCachedMemory(p.TargetPid)
+		if err != nil {
+			slog.Warn("Couldn't get cached memory of the target cgroup, using host cached memory", "error", err)
+		}
+	}
+	if cached.Source == "" {
+		cached, err = memory.GetCachedMemory(p.pageSize)
+		if err != nil {
+			return fmt.Errorf("Couldn't get cached_memory: %v", err)
+		}
+	}
+
+	p.fileMemory = cached.Cached
+	p.memorySource = cached.Source
+	if cached.IncludesShmem {
+		shmem, shmemSource := p.getShmem(ctx, cached)
+		if shmemSource != "" {
+			p.fileMemory = max(cached.Cached-shmem, 0)
+			p.memorySource = fmt.Sprintf("%s - %s", cached.Source, shmemSource)
+		} else {
+			slog.Warn("Couldn't find shared memory size, %Total includes it", "source", cached.Source)
+		}
+		slog.Info("Detected shared memory", "shmem", utils.FormatKBValue(shmem, utils.UnitGB), "source", shmemSource)
+	}
+	slog.Info("Detected cached memory usage", "cache_memory", utils.FormatKBValue(p.fileMemory, utils.UnitGB), "source", p.memorySource)
+	if p.FullWalk && p.Type != FormatColumn {
+		slog.Info(p.getCoverage())
+	}
+	return nil
+}
+
+// storeHistory writes the scan results of relations in the history table
+func (p *PgPageCache) storeHistory(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, p.StoreTo)
+	if err != nil {
+		return fmt.Errorf("error connecting to history database: %v", err)
+	}
+	defer conn.Close(ctx)
+
+	host, err := os.Hostname()
+	if err != nil {
+		return fmt.Errorf("error getting hostname: %v", err)
+	}
+	if p.Container != "" {
+		host = p.Container
+	}
+	// Evicted relations are stored with 0 cached pages instead of leaving gaps
+	relinfos := p.scannedRelinfos
+	err = relation.StoreHistory(ctx, conn, p.StoreTable, host, p.database, relinfos)
+	if err != nil {
+		return err
+	}
+	slog.Info("Stored scan results", "table", p.StoreTable, "relations", len(relinfos))
+	return nil
+}
+
 // fillPartitionStats iterate over tableToRelinfos and fetch page cache stats
 func (p *PgPageCache) fillPartitionStats() error {
 	for partName, partInfo := range p.partitions {
@@ -90,6 +214,11 @@ func (p *PgPageCache) fillPartitionStats() error {
 			if err != nil {
 				return err
 			}
+			if tableInfo.PageCount <= p.PageThreshold {
+				// All relations were under the page threshold
+				delete(partInfo.TableInfos, tableName)
+				continue
+			}
 			partInfo.Add(tableInfo.PageStats)
 			partInfo.TableInfos[tableName] = tableInfo
 		}
@@ -98,15 +227,84 @@ func (p *PgPageCache) fillPartitionStats() error {
 	return nil
 }
 
-// getWalPageStats fetches page cache usage of WAL files
-func (p *PgPageCache) getWalPageStats() (walPageStats pagecache.PageStats, err error) {
-	baseDir := path.Join(p.PgData, "pg_wal")
+// getWalPosition returns the current WAL position with the segment size.
+// Without connection, the most recently modified segment is the current one
+// and the segment size is the size of a segment file. On standbys, the
+// current timeline is the newest timeline of the segments
+func (p *PgPageCache) getWalPosition(ctx context.Context, baseDir string, entries []os.DirEntry) (current relation.WalPosition, segmentSize int64, err error) {
+	if p.conn != nil {
+		var position *relation.WalPosition
+		position, segmentSize, err = relation.GetWalPosition(ctx, p.conn, p.version)
+		if err != nil {
+			return current, 0, err
+		}
+		if position != nil && position.Timeline != 0 {
+			return *position, segmentSize, nil
+		}
+		if position != nil {
+			current = *position
+			for _, entry := range entries {
+				timeline, _, ok := relation.ParseWalSegno(entry.Name(), segmentSize)
+				if ok {
+					current.Timeline = max(current.Timeline, timeline)
+				}
+			}
+			return current, segmentSize, nil
+		}
+	}
+
+	var lastModTime time.Time
+	var currentName string
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || len(entry.Name()) != 24 {
+			continue
+		}
+		if info.ModTime().After(lastModTime) {
+			lastModTime = info.ModTime()
+			currentName = entry.Name()
+			segmentSize = info.Size()
+		}
+	}
+	if currentName == "" {
+		return current, 0, fmt.Errorf("no WAL segment found in %s", baseDir)
+	}
+	timeline, segno, ok := relation.ParseWalSegno(currentName, segmentSize)
+	if !ok {
+		return current, 0, fmt.Errorf("couldn't parse WAL segment %s", currentName)
+	}
+	return relation.WalPosition{Timeline: timeline, Segno: segno}, segmentSize, nil
+}
+
+// getArchiveStatus returns the archive status of WAL files, ready or done
+func getArchiveStatus(baseDir string) map[string]string {
+	archiveStatus := make(map[string]string, 0)
+	entries, err := os.ReadDir(path.Join(baseDir, "archive_status"))
+	if err != nil {
+		return archiveStatus
+	}
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		archiveStatus[strings.TrimSuffix(entry.Name(), ext)] = strings.TrimPrefix(ext, ".")
+	}
+	return archiveStatus
+}
+
+// getWalInfos fetches page cache usage of WAL files, grouped by their class
+func (p *PgPageCache) getWalInfos(ctx context.Context) (walInfos []relation.BaseInfo, err error) {
+	baseDir := p.resolveLink(path.Join(p.PgData, p.version.WalDir()))
 	entries, err := os.ReadDir(baseDir)
 	if err != nil {
 		err = fmt.Errorf("Error listing file: %v", err)
 		return
 	}
+	current, segmentSize, err := p.getWalPosition(ctx, baseDir, entries)
+	if err != nil {
+		return
+	}
+	archiveStatus := getArchiveStatus(baseDir)
 
+	classStats := make(map[string]pagecache.PageStats, 0)
 	for _, entry := range entries {
 		var pageStats pagecache.PageStats
 		var fsInfo os.FileInfo
@@ -122,7 +320,18 @@ func (p *PgPageCache) getWalPageStats() (walPageStats pagecache.PageStats, err e
 		if err != nil {
 			return
 		}
-		walPageStats.Add(pageStats)
+		class := relation.ClassifyWalFile(entry.Name(), current, segmentSize, archiveStatus)
+		stats := classStats[class]
+		stats.Add(pageStats)
+		classStats[class] = stats
+	}
+
+	for _, class := range relation.WalClasses {
+		stats, ok := classStats[class]
+		if !ok {
+			continue
+		}
+		walInfos = append(walInfos, relation.BaseInfo{PageStats: stats, Name: "WAL " + class, Kind: 'W'})
 	}
 	return
 }
@@ -135,25 +344,31 @@ func NewPgPagecache(conn *pgx.Conn, cliArgs CliArgs) (pgPagecache PgPageCache) {
 	return
 }
 
-func (p *PgPageCache) getOutputInfos() (outputInfos []relation.OutputInfo) {
-	if p.GroupPartition {
-		outputInfos = p.getAggregatedPartitions()
-	} else if p.GroupTable {
-		outputInfos = p.getAggregatedTables()
-	} else {
-		outputInfos = p.getNoAggregations()
-	}
+func (p *PgPageCache) getOutputLines() (lines []outputLine) {
+	lines = p.getAggregations()
 	if p.ScanWal {
-		outputInfos = append(outputInfos, &relation.WalInfo)
+		for i := range p.walInfos {
+			lines = append(lines, outputLine{&p.walInfos[i], p.padDimensions(nil)})
+		}
 	}
-	outputInfos = append(outputInfos, &relation.TotalInfo)
+	for i := range p.dirInfos {
+		lines = append(lines, outputLine{&p.dirInfos[i], p.padDimensions(nil)})
+	}
+	if p.FullWalk {
+		lines = append(lines, outputLine{&p.unattributed, p.padDimensions(nil)})
+	}
+	for i := range p.tempInfos {
+		if p.Limit > 0 && i >= p.Limit {
+			break
+		}
+		lines = append(lines, outputLine{&p.tempInfos[i], p.padDimensions(nil)})
+	}
+	lines = append(lines, outputLine{&relation.TotalInfo, p.padDimensions(nil)})
 	return
 }
 
-// Run executes the pg_pagecache. It will fetch database and relation
-// informations from the running postgres, then fetch page cache stats
-// on those relations
-func (p *PgPageCache) Run(ctx context.Context) (err error) {
+// loadCatalog fetches database and relation informations from the running postgres
+func (p *PgPageCache) loadCatalog(ctx context.Context) (err error) {
 	// Fetch dbid and database
 	err = p.conn.QueryRow(ctx, "select oid, datname from pg_database where datname=current_database()").Scan(&p.dbid, &p.database)
 	if err != nil {
@@ -162,36 +377,284 @@ func (p *PgPageCache) Run(ctx context.Context) (err error) {
 	}
 	slog.Info("Fetched database details", "database", p.database, "dbid", p.dbid)
 
+	p.version, err = pgversion.FromServer(ctx, p.conn)
+	if err != nil {
+		return
+	}
+	p.checkDataDirectory(ctx)
+
+	tables := p.Relations
+	if p.CatalogCache != "" {
+		// The cache stores the full catalog, relations are filtered afterwards
+		tables = nil
+	}
+
 	// Fill the partition -> []Table map
-	p.partitions, err = relation.GetPartitionToTables(ctx, p.conn, p.Relations, p.PageThreshold)
+	p.partitions, err = relation.GetPartitionToTables(ctx, p.conn, tables, p.version)
 	if err != nil {
 		err = fmt.Errorf("error getting table to relinfos mapping: %v", err)
 		return
 	}
 
+	if p.CatalogCache != "" {
+		catalogCache := p.refreshCatalogCache()
+		p.partitions = catalogCache.GetPartitionToTables(p.Relations)
+	}
+	return
+}
+
+// checkDataDirectory warns if pg_data doesn't match the data directory of the connected server
+func (p *PgPageCache) checkDataDirectory(ctx context.Context) {
+	var dataDirectory string
+	err := p.conn.QueryRow(ctx, "SHOW data_directory").Scan(&dataDirectory)
+	if err != nil {
+		slog.Debug("Couldn't get data_directory", "error", err)
+		return
+	}
+	pgData, err := filepath.EvalSymlinks(p.PgData)
+	if err != nil {
+		pgData = p.PgData
+	}
+	serverDataDir, err := filepath.EvalSymlinks(path.Join(p.RootDir, dataDirectory))
+	if err != nil {
+		serverDataDir = path.Join(p.RootDir, dataDirectory)
+	}
+	if filepath.Clean(pgData) != filepath.Clean(serverDataDir) {
+		slog.Warn("pg_data doesn't match the data directory of the connected server", "pgData", p.PgData, "dataDirectory", dataDirectory)
+	}
+}
+
+// refreshCatalogCache saves the current mapping in the catalog cache file and
+// logs relfilenode changes since the last save
+func (p *PgPageCache) refreshCatalogCache() relation.CatalogCache {
+	catalogCache := relation.NewCatalogCache(p.database, p.dbid, p.partitions)
+	previous, err := relation.LoadCatalogCache(p.CatalogCache)
+	if err == nil && previous.Dbid == p.dbid {
+		catalogCache.LogRelfilenodeChanges(previous)
+	}
+	err = catalogCache.Save(p.CatalogCache)
+	if err != nil {
+		// Not fatal, we still have a valid mapping
+		slog.Warn("Couldn't save catalog cache", "path", p.CatalogCache, "error", err)
+	}
+	return catalogCache
+}
+
+// applyGroupQuery regroups relations using the labels returned by the group query
+func (p *PgPageCache) applyGroupQuery(ctx context.Context) error {
+	if p.conn == nil {
+		slog.Warn("No connection available, group query is ignored")
+		return nil
+	}
+	labels, err := relation.GetGroupLabels(ctx, p.conn, p.GroupQuery)
+	if err != nil {
+		return err
+	}
+	slog.Info("Fetched group labels", "count", len(labels.Labels), "byRelfilenode", labels.ByRelfilenode)
+	p.partitions = relation.RegroupPartitions(p.partitions, labels)
+	p.customGroups = true
+	return nil
+}
+
+// trackRewrites compares relfilenodes with the previous run's scan state to
+// find rewritten relations, then saves the current scan state
+func (p *PgPageCache) trackRewrites() {
+	// Empty relations left by TRUNCATE are filtered by the page threshold
+	scanState := relation.NewScanState(p.dbid, p.scannedRelinfos)
+	previous, err := relation.LoadScanState(p.StateFile)
+	if err == nil {
+		p.rewrites = scanState.GetRewrites(previous)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		slog.Warn("Couldn't load scan state", "path", p.StateFile, "error", err)
+	}
+	for _, rewrite := range p.rewrites {
+		slog.Info("Relation rewritten", "relation", rewrite.Name, "oid", rewrite.Oid,
+			"old", rewrite.OldRelfilenode, "new", rewrite.NewRelfilenode,
+			"pageCachedLost", rewrite.PageCachedLost)
+	}
+
+	err = scanState.Save(p.StateFile)
+	if err != nil {
+		slog.Warn("Couldn't save scan state", "path", p.StateFile, "error", err)
+	}
+}
+
+// loadCachedCatalog reads database and relation informations from the
+// catalog cache file saved by a previous run
+func (p *PgPageCache) loadCachedCatalog() (err error) {
+	catalogCache, err := relation.LoadCatalogCache(p.CatalogCache)
+	if err != nil {
+		return
+	}
+	slog.Warn("No connection available, using cached catalog", "path", p.CatalogCache,
+		"database", catalogCache.Database, "age", time.Since(catalogCache.Timestamp).Round(time.Second))
+
+	p.database = catalogCache.Database
+	p.dbid = catalogCache.Dbid
+	p.partitions = catalogCache.GetPartitionToTables(p.Relations)
+	return
+}
+
+// loadOfflineCatalog reads database and relation informations from the
+// catalog files in pgdata
+func (p *PgPageCache) loadOfflineCatalog() (err error) {
+	offlineCatalog, err := relation.NewOfflineCatalog(p.PgData)
+	if err != nil {
+		return fmt.Errorf("error opening offline catalog: %v", err)
+	}
+
+	p.database = p.Database
+	p.dbid, err = offlineCatalog.GetDatabase(p.database)
+	if err != nil {
+		return
+	}
+	slog.Info("Read database details", "database", p.database, "dbid", p.dbid)
+
+	p.partitions, err = offlineCatalog.GetPartitionToTables(p.dbid, p.Relations)
+	if err != nil {
+		err = fmt.Errorf("error reading table to relinfos mapping: %v", err)
+		return
+	}
+	return
+}
+
+// Run executes the pg_pagecache. It will fetch database and relation
+// informations from the running postgres, or from the catalog files in
+// offline mode, then fetch page cache stats on those relations
+func (p *PgPageCache) Run(ctx context.Context) (err error) {
+	if p.Offline {
+		err = p.loadOfflineCatalog()
+	} else if p.conn == nil {
+		err = p.loadCachedCatalog()
+	} else {
+		err = p.loadCatalog(ctx)
+	}
+	if err != nil {
+		return
+	}
+
+	if p.conn == nil {
+		// Without connection, use PG_VERSION to find the layout of pgdata
+		p.version, err = pgversion.FromPGData(p.PgData)
+		if err != nil {
+			return
+		}
+	}
+	p.version.CheckSupported()
+	slog.Info("Detected PostgreSQL version", "version", p.version.String())
+
+	if p.GroupQuery != "" {
+		err = p.applyGroupQuery(ctx)
+		if err != nil {
+			return
+		}
+	}
+
 	// Detect page size
 	p.pageSize = pagecache.GetPageSize()
 	slog.Info("Detected Page size", "pageSize", p.pageSize)
 
 	// Go through all tables and fill their pagecache
+	if p.FullWalk {
+		// Keep relations filtered by thresholds to attribute their files
+		p.knownRelfilenodes, err = p.getKnownRelfilenodes(ctx)
+		if err != nil {
+			return
+		}
+	}
 	err = p.fillPartitionStats()
 	if err != nil {
 		return
 	}
 
+	if p.Impact {
+		return p.runImpact(ctx)
+	}
+
+	if p.StateFile != "" {
+		p.trackRewrites()
+	}
+
 	if p.ScanWal {
 		// Get pagecache usage of wal files
-		relation.WalInfo.PageStats, err = p.getWalPageStats()
+		p.walInfos, err = p.getWalInfos(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if p.ScanDirs {
+		// Get pagecache usage of other pgdata directories and logs
+		p.dirInfos, err = p.getPgDataDirInfos(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if p.FullWalk {
+		// Get pagecache usage of all pgdata files
+		p.pgDataStats, p.unattributed, err = p.walkPgData(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if p.ScanTemp {
+		// Get pagecache usage of temporary relations and spill files
+		p.tempInfos, err = p.getTempInfos(ctx)
 		if err != nil {
 			return
 		}
 	}
 
-	p.fileMemory, err = memory.GetCachedMemory(p.pageSize)
+	if p.Buffercache {
+		// Compare shared_buffers with the page cache
+		p.doubleBuffers, err = p.getDoubleBuffering(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	err = p.loadCachedMemory(ctx)
 	if err != nil {
-		return fmt.Errorf("Couldn't get cached_memory: %v", err)
+		return
+	}
+
+	if p.StoreTo != "" {
+		// Every run is stored, whatever the report mode
+		err = p.storeHistory(ctx)
+		if err != nil {
+			return
+		}
+	}
+
+	if p.DirtyReport {
+		return p.outputDirtyReport(ctx)
+	}
+
+	if p.LsnReport {
+		return p.outputLsnReport(ctx)
+	}
+
+	if p.FreeSpaceReport {
+		return p.outputFreeSpaceReport(ctx)
+	}
+
+	if p.BtreeReport {
+		return p.outputBtreeReport(ctx)
+	}
+
+	if p.VisibilityReport {
+		return p.outputVisibilityReport(ctx)
+	}
+
+	if p.StatioReport {
+		// Uncached relations are kept as their reads go to disk
+		return p.outputStatioReport(ctx)
+	}
+	if len(p.doubleBuffers) > 0 {
+		p.applyDoubleBuffering()
 	}
-	slog.Info("Detected cached memory usage", "cache_memory", utils.FormatKBValue(p.fileMemory, utils.UnitGB))
 
 	// Filter partitions under the threshold
 	filteredPartInfos := make(map[string]relation.PartInfo, 0)
@@ -223,6 +686,14 @@ func (p *PgPageCache) Run(ctx context.Context) (err error) {
 		}
 	}
 
-	outputInfos := p.getOutputInfos()
-	return p.outputResults(outputInfos)
+	if p.AutoprewarmFile != "" {
+		return p.exportAutoprewarm(ctx)
+	}
+
+	if p.WasteReport {
+		return p.outputWasteReport(ctx)
+	}
+
+	lines := p.getOutputLines()
+	return p.outputResults(lines)
 }
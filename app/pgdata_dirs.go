@@ -0,0 +1,100 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// getDirPageStats returns the page cache usage of all files under dir
+func (p *PgPageCache) getDirPageStats(dir string) (dirPageStats pagecache.PageStats, err error) {
+	err = filepath.WalkDir(dir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		pageStats, err := p.pageCacheState.GetPageCacheInfo(fullPath, p.pageSize)
+		if err != nil {
+			// Files like stats temp files or spill files can be removed at any time
+			slog.Debug("Skipping file", "path", fullPath, "error", err)
+			return nil
+		}
+		dirPageStats.Add(pageStats)
+		return nil
+	})
+	if err != nil {
+		return dirPageStats, fmt.Errorf("Error walking directory %s: %v", dir, err)
+	}
+	return
+}
+
+// getLogDir returns the server log directory, read from log_directory when connected
+func (p *PgPageCache) getLogDir(ctx context.Context) string {
+	logDir := p.version.DefaultLogDir()
+	if p.conn != nil {
+		err := p.conn.QueryRow(ctx, "SELECT current_setting('log_directory')").Scan(&logDir)
+		if err != nil {
+			slog.Debug("Couldn't get log_directory", "error", err)
+			logDir = p.version.DefaultLogDir()
+		}
+	}
+	if path.IsAbs(logDir) {
+		return path.Join(p.RootDir, logDir)
+	}
+	return path.Join(p.PgData, logDir)
+}
+
+// getPgDataDirInfos fetches page cache usage of pgdata subdirectories,
+// replication slots spill files and server logs
+func (p *PgPageCache) getPgDataDirInfos(ctx context.Context) (dirInfos []relation.BaseInfo, err error) {
+	for _, dir := range p.version.PgDataDirs() {
+		pageStats, err := p.getDirPageStats(path.Join(p.PgData, dir))
+		if err != nil {
+			return nil, err
+		}
+		if pageStats.PageCount > 0 {
+			dirInfos = append(dirInfos, relation.BaseInfo{PageStats: pageStats, Name: dir, Kind: 'D'})
+		}
+	}
+
+	// Attribute spill files to their replication slot
+	slotDir := path.Join(p.PgData, "pg_replslot")
+	slots, err := os.ReadDir(slotDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("Error listing replication slots: %v", err)
+	}
+	for _, slot := range slots {
+		if !slot.IsDir() {
+			continue
+		}
+		pageStats, err := p.getDirPageStats(path.Join(slotDir, slot.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if pageStats.PageCount > 0 {
+			dirInfos = append(dirInfos, relation.BaseInfo{PageStats: pageStats, Name: path.Join("pg_replslot", slot.Name()), Kind: 'R'})
+		}
+	}
+
+	logDir := p.getLogDir(ctx)
+	pageStats, err := p.getDirPageStats(logDir)
+	if err != nil {
+		return nil, err
+	}
+	if pageStats.PageCount > 0 {
+		dirInfos = append(dirInfos, relation.BaseInfo{PageStats: pageStats, Name: path.Base(logDir), Kind: 'L'})
+	}
+	return dirInfos, nil
+}
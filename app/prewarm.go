@@ -0,0 +1,117 @@
+package app
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+// defaultTablespaceOid is the oid of pg_default, storing relations in base
+const defaultTablespaceOid = 1663
+
+// prewarmBlock is a cached block of a relation's main fork
+type prewarmBlock struct {
+	relfilenode uint32
+	block       int64
+	hotness     int
+}
+
+// getPrewarmSettings returns the block and segment sizes with the number of
+// shared buffers. Without connection, block size is read from a relation file
+// and the number of blocks isn't capped
+func (p *PgPageCache) getPrewarmSettings(ctx context.Context) (settings relation.BufferSettings, maxBlocks int64, err error) {
+	if p.conn != nil {
+		settings, err = relation.GetBufferSettings(ctx, p.conn)
+		if err != nil {
+			return
+		}
+		var sharedBuffers int64
+		sharedBuffers, err = relation.GetSharedBuffersSize(ctx, p.conn)
+		return settings, sharedBuffers * 1024 / settings.BlockSize, err
+	}
+
+	slog.Warn("No connection, blocks won't be capped at shared_buffers size")
+	baseDir := path.Join(p.PgData, "base", fmt.Sprintf("%d", p.dbid))
+	for _, relinfo := range p.getRelInfos() {
+		blockSize, err := pgdisk.DetectPageSize(path.Join(baseDir, fmt.Sprintf("%d", relinfo.Relfilenode)))
+		if err == nil {
+			// Segments are 1GB by default
+			return relation.BufferSettings{BlockSize: int64(blockSize), SegmentBlocks: (1 << 30) / int64(blockSize)}, 0, nil
+		}
+	}
+	return settings, 0, fmt.Errorf("couldn't detect block size")
+}
+
+// getPrewarmBlocks returns cached blocks of all relations, sorted by hotness
+func (p *PgPageCache) getPrewarmBlocks(settings relation.BufferSettings) (blocks []prewarmBlock, err error) {
+	for _, relinfo := range p.getRelInfos() {
+		if relinfo.PageCached == 0 {
+			continue
+		}
+		blockHotness, err := p.getBlockValues(relinfo.Relfilenode, settings, func(fullPath string) ([]int, error) {
+			return p.pageCacheState.GetPageHotness(fullPath, p.pageSize)
+		})
+		if err != nil {
+			return nil, err
+		}
+		for block, hotness := range blockHotness {
+			if hotness >= 0 {
+				blocks = append(blocks, prewarmBlock{relinfo.Relfilenode, int64(block), hotness})
+			}
+		}
+	}
+	slices.SortStableFunc(blocks, func(a, b prewarmBlock) int {
+		return cmp.Compare(b.hotness, a.hotness)
+	})
+	return blocks, nil
+}
+
+// exportAutoprewarm writes cached blocks in the autoprewarm.blocks format read
+// by pg_prewarm, keeping the hottest blocks fitting in shared_buffers
+func (p *PgPageCache) exportAutoprewarm(ctx context.Context) error {
+	settings, maxBlocks, err := p.getPrewarmSettings(ctx)
+	if err != nil {
+		return err
+	}
+	if !p.pageCacheState.CanReadPageFlags {
+		slog.Warn("Page flags are not available, blocks won't be ordered by hotness")
+	}
+	blocks, err := p.getPrewarmBlocks(settings)
+	if err != nil {
+		return err
+	}
+	if maxBlocks > 0 && int64(len(blocks)) > maxBlocks {
+		blocks = blocks[:maxBlocks]
+	}
+
+	// pg_prewarm sorts blocks when loading them, keep the same order in the file
+	slices.SortFunc(blocks, func(a, b prewarmBlock) int {
+		return cmp.Or(cmp.Compare(a.relfilenode, b.relfilenode), cmp.Compare(a.block, b.block))
+	})
+
+	f, err := os.Create(p.AutoprewarmFile)
+	if err != nil {
+		return fmt.Errorf("error creating autoprewarm file: %v", err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "<<%d>>\n", len(blocks))
+	for _, b := range blocks {
+		// database, tablespace, relfilenode, fork, block
+		fmt.Fprintf(w, "%d,%d,%d,%d,%d\n", p.dbid, defaultTablespaceOid, b.relfilenode, 0, b.block)
+	}
+	err = w.Flush()
+	if err != nil {
+		return fmt.Errorf("error writing autoprewarm file: %v", err)
+	}
+	slog.Info("Exported cached blocks", "file", p.AutoprewarmFile, "blocks", len(blocks), "maxBlocks", maxBlocks)
+	return f.Close()
+}
@@ -0,0 +1,33 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+)
+
+// forEachSegment calls fn with each segment file of the relation's fork
+// (empty for the main fork, _fsm or _vm), until the next segment doesn't exist
+func (p *PgPageCache) forEachSegment(relfilenode uint32, fork string, fn func(segno int64, fullPath string) error) error {
+	baseDir := path.Join(p.PgData, "base", fmt.Sprintf("%d", p.dbid))
+	for segno := int64(0); ; segno++ {
+		filename := fmt.Sprintf("%d%s", relfilenode, fork)
+		if segno > 0 {
+			filename = fmt.Sprintf("%d%s.%d", relfilenode, fork, segno)
+		}
+		fullPath := path.Join(baseDir, filename)
+		_, err := os.Stat(fullPath)
+		if errors.Is(err, os.ErrNotExist) {
+			// Last segment was processed
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error getting file stat %s: %v", fullPath, err)
+		}
+		err = fn(segno, fullPath)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+var (
+	// Temporary relation files: t<backendid>_<relfilenode>[_<fork>][.<segno>]
+	tempRelationRegex = regexp.MustCompile(`^t(\d+)_(\d+)(?:_(?:fsm|vm|init))?(?:\.\d+)?$`)
+	// Spill files and shared fileset directories: pgsql_tmp<pid>.<n>[.sharedfileset]
+	tempFileRegex = regexp.MustCompile(`^pgsql_tmp(\d+)\.`)
+)
+
+func (p *PgPageCache) sortTempInfos(r []relation.TempInfo) {
+	slices.SortFunc(r, func(a, b relation.TempInfo) int {
+		switch p.Sort {
+		case SortPageCount:
+			return cmp.Or(cmp.Compare(b.PageCount, a.PageCount), cmp.Compare(a.Name, b.Name))
+		case SortPageCached:
+			return cmp.Or(cmp.Compare(b.PageCached, a.PageCached), cmp.Compare(a.Name, b.Name))
+		}
+		return cmp.Compare(a.Name, b.Name)
+	})
+}
+
+// getTablespaceDirs returns the matching directory in all tablespaces' version directory
+func (p *PgPageCache) getTablespaceDirs(name string) []string {
+	tblspcDir := path.Join(p.PgData, "pg_tblspc")
+	entries, err := os.ReadDir(tblspcDir)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, entry := range entries {
+		location := p.resolveLink(path.Join(tblspcDir, entry.Name()))
+		matches, _ := filepath.Glob(path.Join(location, "PG_*", name))
+		dirs = append(dirs, matches...)
+	}
+	return dirs
+}
+
+// getTempRelationInfos scans temporary relation files of the current database
+func (p *PgPageCache) getTempRelationInfos(tempRelations map[uint32]string, activity relation.BackendActivity) (tempInfos map[string]relation.TempInfo, err error) {
+	tempInfos = make(map[string]relation.TempInfo, 0)
+	dbDir := fmt.Sprintf("%d", p.dbid)
+	dirs := append([]string{path.Join(p.PgData, "base", dbDir)}, p.getTablespaceDirs(dbDir)...)
+
+	for _, dir := range dirs {
+		var entries []os.DirEntry
+		entries, err = os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Error listing file: %v", err)
+		}
+		for _, entry := range entries {
+			res := tempRelationRegex.FindStringSubmatch(entry.Name())
+			if res == nil {
+				continue
+			}
+			backendID, _ := strconv.Atoi(res[1])
+			relfilenode, _ := strconv.ParseUint(res[2], 10, 32)
+			key := fmt.Sprintf("t%s_%s", res[1], res[2])
+
+			tempInfo, ok := tempInfos[key]
+			if !ok {
+				tempInfo.Kind = 'X'
+				tempInfo.BackendID = backendID
+				tempInfo.Relfilenode = uint32(relfilenode)
+				tempInfo.Pid = activity.BackendPids[backendID]
+				tempInfo.Query = activity.Queries[tempInfo.Pid]
+				tempInfo.Name, ok = tempRelations[tempInfo.Relfilenode]
+				if !ok {
+					// Relation is not visible from the current connection
+					tempInfo.Name = key
+				}
+			}
+
+			pageStats, err := p.pageCacheState.GetPageCacheInfo(path.Join(dir, entry.Name()), p.pageSize)
+			if err != nil {
+				return nil, err
+			}
+			tempInfo.Add(pageStats)
+			tempInfos[key] = tempInfo
+		}
+	}
+	return
+}
+
+// getTempFileInfos scans sort and hash spill files of all databases
+func (p *PgPageCache) getTempFileInfos(activity relation.BackendActivity) (tempInfos map[string]relation.TempInfo, err error) {
+	tempInfos = make(map[string]relation.TempInfo, 0)
+	dirs := append([]string{path.Join(p.PgData, "base", "pgsql_tmp")}, p.getTablespaceDirs("pgsql_tmp")...)
+
+	for _, dir := range dirs {
+		err = filepath.WalkDir(dir, func(fullPath string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					// pgsql_tmp is only created when needed
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+
+			// Attribute the file to its top level pgsql_tmp entry
+			rel, err := filepath.Rel(dir, fullPath)
+			if err != nil {
+				return err
+			}
+			topLevel, _, _ := strings.Cut(rel, string(filepath.Separator))
+			res := tempFileRegex.FindStringSubmatch(topLevel)
+			if res == nil {
+				return nil
+			}
+
+			tempInfo, ok := tempInfos[topLevel]
+			if !ok {
+				tempInfo.Name = topLevel
+				tempInfo.Kind = 'F'
+				tempInfo.Pid, _ = strconv.Atoi(res[1])
+				tempInfo.Query = activity.Queries[tempInfo.Pid]
+			}
+			pageStats, err := p.pageCacheState.GetPageCacheInfo(fullPath, p.pageSize)
+			if err != nil {
+				// Spill files can be removed at any time
+				slog.Debug("Skipping temporary file", "path", fullPath, "error", err)
+				return nil
+			}
+			tempInfo.Add(pageStats)
+			tempInfos[topLevel] = tempInfo
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error walking temporary directory %s: %v", dir, err)
+		}
+	}
+	return
+}
+
+// getTempInfos fetches page cache usage of temporary relations and spill files
+// and attributes them to their backend
+func (p *PgPageCache) getTempInfos(ctx context.Context) (tempInfos []relation.TempInfo, err error) {
+	var tempRelations map[uint32]string
+	var activity relation.BackendActivity
+	if p.conn != nil {
+		tempRelations, err = relation.GetTempRelations(ctx, p.conn)
+		if err != nil {
+			return
+		}
+		activity, err = relation.GetBackendActivity(ctx, p.conn, p.version)
+		if err != nil {
+			return
+		}
+	}
+
+	tempRelationInfos, err := p.getTempRelationInfos(tempRelations, activity)
+	if err != nil {
+		return
+	}
+	tempFileInfos, err := p.getTempFileInfos(activity)
+	if err != nil {
+		return
+	}
+
+	for _, tempInfo := range tempRelationInfos {
+		tempInfos = append(tempInfos, tempInfo)
+	}
+	for _, tempInfo := range tempFileInfos {
+		tempInfos = append(tempInfos, tempInfo)
+	}
+	p.sortTempInfos(tempInfos)
+	return
+}
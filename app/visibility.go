@@ -0,0 +1,85 @@
+package app
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/relation"
+)
+
+var visibilityHeader = []string{"Relation", "Kind", "Cached", "All Visible", "All Frozen", "Not Visible", "%Not Visible"}
+
+// getVisibilityInfos combines the block residency of each table with its visibility map
+func (p *PgPageCache) getVisibilityInfos() (visibilityInfos []relation.VisibilityInfo, err error) {
+	for _, relinfo := range p.getRelInfos() {
+		if relinfo.PageCached == 0 || !relation.IsHeapKind(relinfo.Kind) {
+			continue
+		}
+		residency, err := p.getBlockResidency(relinfo.Relfilenode, p.bufferSettings)
+		if err != nil {
+			return nil, err
+		}
+		var vmPaths []string
+		err = p.forEachSegment(relinfo.Relfilenode, "_vm", func(_ int64, fullPath string) error {
+			vmPaths = append(vmPaths, fullPath)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		vm, err := pgdisk.ReadVisibilityMap(vmPaths, int(p.bufferSettings.BlockSize))
+		if err != nil {
+			return nil, err
+		}
+
+		v := relation.VisibilityInfo{Name: relinfo.Name, Kind: relinfo.Kind}
+		for block, resident := range residency {
+			if !resident {
+				continue
+			}
+			v.CachedBlocks++
+			allVisible, allFrozen := vm.GetStatus(int64(block))
+			switch {
+			case allFrozen:
+				v.AllFrozen++
+			case allVisible:
+				v.AllVisible++
+			default:
+				v.NotVisible++
+			}
+		}
+		if v.CachedBlocks > 0 {
+			visibilityInfos = append(visibilityInfos, v)
+		}
+	}
+	slices.SortFunc(visibilityInfos, func(a, b relation.VisibilityInfo) int {
+		return cmp.Or(cmp.Compare(b.NotVisible, a.NotVisible), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// outputVisibilityReport outputs the cached heap blocks per visibility map status
+func (p *PgPageCache) outputVisibilityReport(ctx context.Context) error {
+	err := p.loadBufferSettings(ctx)
+	if err != nil {
+		return err
+	}
+	visibilityInfos, err := p.getVisibilityInfos()
+	if err != nil {
+		return err
+	}
+
+	total := relation.VisibilityInfo{Name: "Total", Kind: 'S'}
+	var values [][]string
+	for i := range visibilityInfos {
+		total.Add(visibilityInfos[i])
+		if p.Limit > 0 && i >= p.Limit {
+			continue
+		}
+		values = append(values, visibilityInfos[i].ToStringArray(p.Unit, p.bufferSettings.BlockSize))
+	}
+	values = append(values, total.ToStringArray(p.Unit, p.bufferSettings.BlockSize))
+	return p.outputReport(visibilityHeader, values)
+}
@@ -47,19 +47,27 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	// Get the db connection
-	config, err := pgx.ParseConfig(cliArgs.ConnectString)
-	if err != nil {
-		slog.Error("Error parsing connection string", "error", err)
-		os.Exit(1)
-	}
-	config.Tracer = queryTracer{}
-	conn, err := pgx.ConnectConfig(ctx, config)
-	if err != nil {
-		slog.Error("Unable to connect to database", "error", err)
-		os.Exit(1)
+	// Get the db connection, catalogs are read from disk in offline mode
+	var conn *pgx.Conn
+	if !cliArgs.Offline {
+		config, err := pgx.ParseConfig(cliArgs.ConnectString)
+		if err != nil {
+			slog.Error("Error parsing connection string", "error", err)
+			os.Exit(1)
+		}
+		config.Tracer = queryTracer{}
+		conn, err = pgx.ConnectConfig(ctx, config)
+		if err != nil && cliArgs.CatalogCache != "" {
+			// Fallback to the cached catalog
+			slog.Warn("Unable to connect to database", "error", err)
+			conn = nil
+		} else if err != nil {
+			slog.Error("Unable to connect to database", "error", err)
+			os.Exit(1)
+		} else {
+			defer conn.Close(ctx)
+		}
 	}
-	defer conn.Close(ctx)
 
 	// Build PgPagecache struct
 	pgPagecache := app.NewPgPagecache(conn, cliArgs)
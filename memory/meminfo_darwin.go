@@ -13,19 +13,19 @@ import (
 	"time"
 )
 
-// GetCachedMemory fetches the size of cached memory in kb
-func GetCachedMemory(pageSize int64) (int64, error) {
+// GetCachedMemory fetches the size of file-backed memory, which doesn't include shared memory
+func GetCachedMemory(pageSize int64) (CachedMemory, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	cmd := exec.CommandContext(ctx, "vm_stat")
 	out, err := cmd.StdoutPipe()
 	if err != nil {
-		return 0, err
+		return CachedMemory{}, err
 	}
 
 	if err := cmd.Start(); err != nil {
-		return 0, err
+		return CachedMemory{}, err
 	}
 
 	scanner := bufio.NewScanner(out)
@@ -40,11 +40,26 @@ func GetCachedMemory(pageSize int64) (int64, error) {
 		file_backed_pages, err := strconv.ParseInt(res[1], 10, 32)
 		if err != nil {
 			slog.Error("couldn't parse cached memory", "error", err)
-			return 0, err
+			return CachedMemory{}, err
 		}
 
-		return file_backed_pages * pageSize / 1024, err
+		return CachedMemory{Cached: file_backed_pages * pageSize / 1024, Source: "vm_stat file-backed"}, err
 	}
 
-	return 0, fmt.Errorf("cached memory not found")
+	return CachedMemory{}, fmt.Errorf("cached memory not found")
+}
+
+// GetProcessCachedMemory is not supported without cgroups
+func GetProcessCachedMemory(pid int) (CachedMemory, error) {
+	return CachedMemory{}, fmt.Errorf("process cgroup is not supported on darwin")
+}
+
+// GetRssShmem is not supported without /proc
+func GetRssShmem(pid int) (int64, error) {
+	return 0, fmt.Errorf("process status is not supported on darwin")
+}
+
+// GetDirtyState is not supported without /proc
+func GetDirtyState(pid int) (DirtyState, error) {
+	return DirtyState{}, fmt.Errorf("dirty memory is not supported on darwin")
 }
@@ -7,6 +7,8 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -41,21 +43,155 @@ func getValue(filePath string, startPattern string) (int64, error) {
 	return 0, fmt.Errorf("Pattern not found")
 }
 
-// GetCachedMemory fetches cached memory from /proc/memory. Returns memory in kb
-func GetCachedMemory(pageSize int64) (int64, error) {
+// getCgroupCachedMemory reads cached memory and the shmem it includes from a
+// cgroup memory.stat, in bytes
+func getCgroupCachedMemory(statPath string, pattern string, source string) (c CachedMemory, err error) {
+	cacheMem, err := getValue(statPath, pattern)
+	if err != nil {
+		return c, err
+	}
+	c = CachedMemory{Cached: cacheMem / 1024, Source: source, IncludesShmem: true}
+	// Both cgroupv2 file and cgroupv1 cache account shmem
+	shmem, err := getValue(statPath, "shmem")
+	if err == nil {
+		c.Shmem = shmem / 1024
+		c.ShmemSource = "cgroup shmem"
+	}
+	return c, nil
+}
+
+// GetCachedMemory fetches cached memory from the cgroup or /proc/meminfo
+func GetCachedMemory(pageSize int64) (CachedMemory, error) {
 	// Check cgroupv2 first
-	fileMem, err := getValue("/sys/fs/cgroup/memory.stat", "file")
+	c, err := getCgroupCachedMemory("/sys/fs/cgroup/memory.stat", "file", "cgroup file")
 	if err == nil {
-		return fileMem / 1024, nil
+		return c, nil
 	}
 
 	// Check cgroupv1
-	cacheMem, err := getValue("/sys/fs/cgroup/memory/memory.stat", "cache")
+	c, err = getCgroupCachedMemory("/sys/fs/cgroup/memory/memory.stat", "cache", "cgroup cache")
 	if err == nil {
-		return cacheMem / 1024, nil
+		return c, nil
 	}
 
-	// Fallback to meminfo
+	// Fallback to meminfo, Cached includes Shmem
 	meminfoVal, err := getValue("/proc/meminfo", "Cached:")
-	return meminfoVal, err
+	if err != nil {
+		return c, err
+	}
+	c = CachedMemory{Cached: meminfoVal, Source: "meminfo Cached", IncludesShmem: true}
+	shmem, err := getValue("/proc/meminfo", "Shmem:")
+	if err == nil {
+		c.Shmem = shmem
+		c.ShmemSource = "meminfo Shmem"
+	}
+	return c, nil
+}
+
+// GetRssShmem returns the resident shared memory of the process from its status, in kb.
+// Unlike Pss, it isn't divided between the backends mapping the same pages
+func GetRssShmem(pid int) (int64, error) {
+	return getValue(fmt.Sprintf("/proc/%d/status", pid), "RssShmem:")
+}
+
+// getCgroupMemoryStat returns the memory.stat path of the process' cgroup
+func getCgroupMemoryStat(pid int) (string, string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err != nil {
+		return "", "", err
+	}
+	for _, line := range strings.Split(string(content), "\n") {
+		// Format is hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			// cgroupv2
+			return path.Join("/sys/fs/cgroup", parts[2], "memory.stat"), "file", nil
+		}
+		if slices.Contains(strings.Split(parts[1], ","), "memory") {
+			// cgroupv1
+			return path.Join("/sys/fs/cgroup/memory", parts[2], "memory.stat"), "cache", nil
+		}
+	}
+	return "", "", fmt.Errorf("memory cgroup of pid %d not found", pid)
+}
+
+// GetProcessCachedMemory fetches cached memory of the process' cgroup
+func GetProcessCachedMemory(pid int) (CachedMemory, error) {
+	statPath, pattern, err := getCgroupMemoryStat(pid)
+	if err != nil {
+		return CachedMemory{}, err
+	}
+	c, err := getCgroupCachedMemory(statPath, pattern, "cgroup "+pattern)
+	if err != nil {
+		return c, fmt.Errorf("error reading %s: %v", statPath, err)
+	}
+	return c, nil
+}
+
+// readSysctl reads an integer value from /proc/sys/vm
+func readSysctl(name string) (int64, error) {
+	content, err := os.ReadFile(path.Join("/proc/sys/vm", name))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+}
+
+// getDirtyThreshold returns the threshold in kb, set either in bytes or as a
+// ratio of the available memory
+func getDirtyThreshold(bytesName string, ratioName string, availableKB int64) int64 {
+	dirtyBytes, err := readSysctl(bytesName)
+	if err == nil && dirtyBytes > 0 {
+		return dirtyBytes / 1024
+	}
+	ratio, err := readSysctl(ratioName)
+	if err != nil {
+		return 0
+	}
+	return availableKB * ratio / 100
+}
+
+// GetDirtyState fetches dirty and writeback memory of the process' cgroup, or
+// of the root cgroup or meminfo without pid, with the vm dirty_* thresholds
+func GetDirtyState(pid int) (d DirtyState, err error) {
+	statPath := "/sys/fs/cgroup/memory.stat"
+	dirtyField, writebackField := "file_dirty", "file_writeback"
+	if pid != 0 {
+		var pattern string
+		statPath, pattern, err = getCgroupMemoryStat(pid)
+		if err != nil {
+			return d, err
+		}
+		if pattern == "cache" {
+			dirtyField, writebackField = "dirty", "writeback"
+		}
+	}
+	dirty, err := getValue(statPath, dirtyField)
+	if err == nil {
+		writeback, _ := getValue(statPath, writebackField)
+		d.Dirty, d.Writeback, d.Source = dirty/1024, writeback/1024, "cgroup "+dirtyField
+	} else {
+		d.Dirty, err = getValue("/proc/meminfo", "Dirty:")
+		if err != nil {
+			return d, err
+		}
+		d.Writeback, _ = getValue("/proc/meminfo", "Writeback:")
+		d.Source = "meminfo Dirty"
+	}
+
+	// Ratios apply to the dirtyable memory, approximated with MemAvailable
+	available, err := getValue("/proc/meminfo", "MemAvailable:")
+	if err != nil {
+		return d, err
+	}
+	d.BackgroundThreshold = getDirtyThreshold("dirty_background_bytes", "dirty_background_ratio", available)
+	d.Threshold = getDirtyThreshold("dirty_bytes", "dirty_ratio", available)
+	expire, err := readSysctl("dirty_expire_centisecs")
+	if err == nil {
+		d.ExpireSecs = float64(expire) / 100
+	}
+	return d, nil
 }
@@ -0,0 +1,27 @@
+package memory
+
+// CachedMemory is the cached memory reported by the system, in kb
+type CachedMemory struct {
+	Cached int64
+	// Source is the file and field the cached memory was read from
+	Source string
+	// IncludesShmem is true when shared memory is accounted as cached memory
+	IncludesShmem bool
+	// Shmem is the shared memory reported by ShmemSource, empty if unavailable
+	Shmem       int64
+	ShmemSource string
+}
+
+// DirtyState is the kernel's dirty page cache with its writeback thresholds, in kb
+type DirtyState struct {
+	Dirty     int64
+	Writeback int64
+	// Source is the file the dirty and writeback values were read from
+	Source string
+	// BackgroundThreshold is the dirty memory starting background writeback
+	BackgroundThreshold int64
+	// Threshold is the dirty memory blocking writers
+	Threshold int64
+	// ExpireSecs is the age after which dirty pages are written back
+	ExpireSecs float64
+}
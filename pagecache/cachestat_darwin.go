@@ -0,0 +1,10 @@
+//go:build darwin
+
+package pagecache
+
+import "fmt"
+
+// GetFileDirtyStats is not supported without cachestat
+func GetFileDirtyStats(fullPath string) (DirtyStats, error) {
+	return DirtyStats{}, fmt.Errorf("cachestat is not supported on darwin")
+}
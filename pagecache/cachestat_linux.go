@@ -0,0 +1,27 @@
+//go:build linux
+
+package pagecache
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetFileDirtyStats returns dirty and writeback pages of the file using cachestat, available from Linux 6.5
+func GetFileDirtyStats(fullPath string) (DirtyStats, error) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return DirtyStats{}, fmt.Errorf("Error opening file %s: %v", fullPath, err)
+	}
+	defer file.Close()
+
+	// A zero length range covers the whole file
+	var cstat unix.Cachestat_t
+	err = unix.Cachestat(uint(file.Fd()), &unix.CachestatRange{}, &cstat, 0)
+	if err != nil {
+		return DirtyStats{}, fmt.Errorf("cachestat failed for %s: %v", fullPath, err)
+	}
+	return DirtyStats{Dirty: int(cstat.Dirty), Writeback: int(cstat.Writeback)}, nil
+}
@@ -0,0 +1,25 @@
+//go:build darwin
+
+package pagecache
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// OpenRandomAccess opens the file with readahead disabled, so reading a
+// resident page doesn't pull the following pages in the page cache
+func OpenRandomAccess(fullPath string) (*os.File, error) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file %s: %v", fullPath, err)
+	}
+	_, err = unix.FcntlInt(file.Fd(), unix.F_RDAHEAD, 0)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Error disabling readahead for %s: %v", fullPath, err)
+	}
+	return file, nil
+}
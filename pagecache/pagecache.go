@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"runtime"
+	"slices"
 	"strconv"
 	"unsafe"
 
@@ -26,6 +27,31 @@ type PageStats struct {
 	PageFlagsMap map[uint64]PageFlags
 }
 
+// DirtyStats stores the number of dirty and writeback pages
+type DirtyStats struct {
+	Dirty     int
+	Writeback int
+}
+
+// Add adds dirty and writeback pages from provided dirtyStats
+func (d *DirtyStats) Add(b DirtyStats) {
+	d.Dirty += b.Dirty
+	d.Writeback += b.Writeback
+}
+
+// GetDirtyStats counts dirty and writeback pages from the page flags
+func (p *PageStats) GetDirtyStats() (d DirtyStats) {
+	for flags, pfs := range p.PageFlagsMap {
+		if flags&(1<<kpfDirty) != 0 {
+			d.Dirty += pfs.Count
+		}
+		if flags&(1<<kpfWriteback) != 0 {
+			d.Writeback += pfs.Count
+		}
+	}
+	return
+}
+
 // State stores state for page cache related functions
 type State struct {
 	rawFlags         bool
@@ -91,16 +117,8 @@ func readInt64SliceFromFile(f *os.File, size int, index int64) ([]uint64, error)
 	return unsafe.Slice(ui64Ptr, ui64Len), nil
 }
 
-func (s *State) getPagecacheStats(fd int, fileSize int64, pageSize int64) (PageStats, error) {
-	var mmap []byte
-	pageStats := PageStats{0, 0, make(map[uint64]PageFlags, 0)}
-	// void *mmap(void addr[.length], size_t length, int prot, int flags, int fd, off_t offset);
-	mmap, err := unix.Mmap(fd, 0, int(fileSize), unix.PROT_READ, unix.MAP_SHARED)
-	if err != nil {
-		return pageStats, fmt.Errorf("Error while mmaping: %v", err)
-	}
-	defer unix.Munmap(mmap)
-
+// mincore returns the residency vector of the mmaped file
+func mincore(mmap []byte, fileSize int64, pageSize int64) ([]byte, error) {
 	// Mincore signature:
 	// int mincore(void addr[.length], size_t length, unsigned char *vec);
 	// Build the result vec. From mincore doc: The vec argument must point to an
@@ -114,8 +132,28 @@ func (s *State) getPagecacheStats(fd int, fileSize int64, pageSize int64) (PageS
 
 	ret, _, err := syscall.Syscall(syscall.SYS_MINCORE, mmapPtr, fileSizePtr, vecPtr)
 	if ret != 0 {
-		return pageStats, fmt.Errorf("syscall SYS_MINCORE failed: %v", err)
+		return nil, fmt.Errorf("syscall SYS_MINCORE failed: %v", err)
 	}
+	return vec, nil
+}
+
+func (s *State) getPagecacheStats(fd int, fileSize int64, pageSize int64) (PageStats, error) {
+	var mmap []byte
+	pageStats := PageStats{0, 0, make(map[uint64]PageFlags, 0)}
+	// void *mmap(void addr[.length], size_t length, int prot, int flags, int fd, off_t offset);
+	mmap, err := unix.Mmap(fd, 0, int(fileSize), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return pageStats, fmt.Errorf("Error while mmaping: %v", err)
+	}
+	defer unix.Munmap(mmap)
+
+	vec, err := mincore(mmap, fileSize, pageSize)
+	if err != nil {
+		return pageStats, err
+	}
+	numPages := int64(len(vec))
+	mmapPtr := uintptr(unsafe.Pointer(&mmap[0]))
+	fileSizePtr := uintptr(fileSize)
 
 	pageStats.PageCount = len(vec)
 	pageStats.PageCached = 0
@@ -214,3 +252,102 @@ func (s *State) GetPageCacheInfo(fullPath string, pagesize int64) (PageStats, er
 	}
 	return pageStats, nil
 }
+
+// mmapFile maps the provided file. Empty files return a nil mapping
+func mmapFile(fullPath string) ([]byte, error) {
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening file %s: %v", fullPath, err)
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("Error getting file stat %s: %v", fullPath, err)
+	}
+	fileSize := fileInfo.Size()
+	if fileSize == 0 {
+		return nil, nil
+	}
+	mmap, err := unix.Mmap(int(file.Fd()), 0, int(fileSize), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, fmt.Errorf("Error while mmaping %s: %v", fullPath, err)
+	}
+	return mmap, nil
+}
+
+// GetResidency returns the residency of each os page of the provided file
+func GetResidency(fullPath string, pageSize int64) ([]bool, error) {
+	mmap, err := mmapFile(fullPath)
+	if err != nil || mmap == nil {
+		return nil, err
+	}
+	defer unix.Munmap(mmap)
+
+	vec, err := mincore(mmap, int64(len(mmap)), pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("Getting residency for %s failed: %v", fullPath, err)
+	}
+	residency := make([]bool, len(vec))
+	for i, v := range vec {
+		residency[i] = v&0x1 > 0
+	}
+	return residency, nil
+}
+
+// getResidentPagemap returns the mincore vector of the mapping with the
+// pagemap entries of its resident pages when page flags are readable. The
+// mapping is released before returning, so reading kpageflags afterwards
+// isn't affected by our own accesses
+func (s *State) getResidentPagemap(mmap []byte, pageSize int64) (vec []byte, pagemapFlags []uint64, err error) {
+	defer unix.Munmap(mmap)
+	vec, err = mincore(mmap, int64(len(mmap)), pageSize)
+	if err != nil || !s.CanReadPageFlags || !slices.ContainsFunc(vec, func(v byte) bool { return v&0x1 > 0 }) {
+		return vec, nil, err
+	}
+	mmapPtr := uintptr(unsafe.Pointer(&mmap[0]))
+	err = s.populatePTE(mmapPtr, uintptr(len(mmap)), vec, pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	pagemapFlags, err = s.readPageMap(mmapPtr, len(vec), pageSize)
+	return vec, pagemapFlags, err
+}
+
+// GetPageHotness returns the hotness of each os page of the provided file: -1
+// when not resident, then increased when the page is referenced and active.
+// Without access to page flags, resident pages have a hotness of 0
+func (s *State) GetPageHotness(fullPath string, pageSize int64) ([]int, error) {
+	mmap, err := mmapFile(fullPath)
+	if err != nil || mmap == nil {
+		return nil, err
+	}
+	vec, pagemapFlags, err := s.getResidentPagemap(mmap, pageSize)
+	if err != nil {
+		return nil, fmt.Errorf("Getting page hotness for %s failed: %v", fullPath, err)
+	}
+	hotness := make([]int, len(vec))
+	for i, v := range vec {
+		hotness[i] = -1
+		if v&0x1 > 0 {
+			hotness[i] = 0
+		}
+	}
+
+	for i, pme := range pagemapFlags {
+		pfn := pme & PFN_MASK
+		if hotness[i] < 0 || pfn == 0 {
+			continue
+		}
+		flagSlice, err := readInt64SliceFromFile(s.kpageFlagsFile, 1, int64(pfn))
+		if err != nil {
+			return nil, err
+		}
+		if flagSlice[0]&(1<<kpfReferenced) != 0 {
+			hotness[i]++
+		}
+		if flagSlice[0]&(1<<kpfActive) != 0 {
+			hotness[i] += 2
+		}
+	}
+	return hotness, nil
+}
@@ -0,0 +1,57 @@
+package pgdisk
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// BtreePageType is the type of a btree page from its special space flags
+type BtreePageType int
+
+const (
+	// BtreeMeta is the metapage, always block 0
+	BtreeMeta BtreePageType = iota
+	// BtreeRoot is the root page, which is also a leaf in single level trees
+	BtreeRoot
+	// BtreeInternal is an internal page below the root
+	BtreeInternal
+	// BtreeLeaf is a leaf page pointing to heap tuples
+	BtreeLeaf
+	// BtreeDeleted is a deleted page waiting to be recycled
+	BtreeDeleted
+	// BtreeHalfDead is a page whose deletion was interrupted
+	BtreeHalfDead
+
+	// btpo_flags bits
+	btpLeaf     = 1 << 0
+	btpRoot     = 1 << 1
+	btpDeleted  = 1 << 2
+	btpMeta     = 1 << 3
+	btpHalfDead = 1 << 4
+
+	// btreeOpaqueSize is the size of BTPageOpaqueData: btpo_prev, btpo_next,
+	// btpo_level, btpo_flags and btpo_cycleid
+	btreeOpaqueSize  = 16
+	btreeFlagsOffset = 12
+)
+
+// GetBtreePageType returns the type of a btree page using btpo_flags
+func GetBtreePageType(page []byte, h PageHeader) (BtreePageType, error) {
+	if int(h.Special)+btreeOpaqueSize > len(page) {
+		return 0, fmt.Errorf("invalid btree special space offset %d", h.Special)
+	}
+	flags := binary.NativeEndian.Uint16(page[int(h.Special)+btreeFlagsOffset:])
+	switch {
+	case flags&btpDeleted != 0:
+		return BtreeDeleted, nil
+	case flags&btpHalfDead != 0:
+		return BtreeHalfDead, nil
+	case flags&btpMeta != 0:
+		return BtreeMeta, nil
+	case flags&btpRoot != 0:
+		return BtreeRoot, nil
+	case flags&btpLeaf != 0:
+		return BtreeLeaf, nil
+	}
+	return BtreeInternal, nil
+}
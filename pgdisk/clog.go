@@ -0,0 +1,82 @@
+package pgdisk
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+)
+
+// XactStatus is the status of a transaction stored in pg_xact
+type XactStatus int
+
+const (
+	// XactInProgress is an in progress (or crashed) transaction
+	XactInProgress XactStatus = iota
+	// XactCommitted is a committed transaction
+	XactCommitted
+	// XactAborted is an aborted transaction
+	XactAborted
+	// XactSubCommitted is a committed subtransaction whose parent's status decides
+	XactSubCommitted
+
+	clogXactsPerByte    = 4
+	slruPagesPerSegment = 32
+	clogBitsPerXact     = 2
+	clogXactBitmask     = (1 << clogBitsPerXact) - 1
+)
+
+// Clog reads transaction status from pg_xact
+type Clog struct {
+	dir      string
+	pageSize int
+	segments map[uint32][]byte
+}
+
+// NewClog creates a reader for the transaction status directory of the
+// provided pgdata, pg_xact or pg_clog before 10
+func NewClog(pgData string, xactDir string, pageSize int) *Clog {
+	return &Clog{
+		dir:      path.Join(pgData, xactDir),
+		pageSize: pageSize,
+		segments: make(map[uint32][]byte, 0),
+	}
+}
+
+// GetStatus returns the status of the provided xid
+func (c *Clog) GetStatus(xid uint32) (XactStatus, error) {
+	if xid < firstNormalTransactionID {
+		// Bootstrap and frozen xids are always committed
+		return XactCommitted, nil
+	}
+
+	xactsPerSegment := uint32(c.pageSize * clogXactsPerByte * slruPagesPerSegment)
+	segno := xid / xactsPerSegment
+	segment, ok := c.segments[segno]
+	if !ok {
+		var err error
+		segment, err = os.ReadFile(path.Join(c.dir, fmt.Sprintf("%04X", segno)))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return XactInProgress, fmt.Errorf("error reading pg_xact segment: %v", err)
+		}
+		if err != nil {
+			// Segment was truncated, all its transactions are older than the
+			// oldest frozen xid and can be considered committed
+			slog.Debug("pg_xact segment not found, assuming committed", "segno", segno)
+		}
+		c.segments[segno] = segment
+	}
+	if segment == nil {
+		return XactCommitted, nil
+	}
+
+	offset := xid % xactsPerSegment
+	byteno := offset / clogXactsPerByte
+	if int(byteno) >= len(segment) {
+		// Not written yet, transaction was never committed
+		return XactInProgress, nil
+	}
+	shift := (offset % clogXactsPerByte) * clogBitsPerXact
+	return XactStatus((segment[byteno] >> shift) & clogXactBitmask), nil
+}
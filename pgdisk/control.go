@@ -0,0 +1,123 @@
+package pgdisk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// DBState is the cluster state stored in pg_control
+type DBState uint32
+
+const (
+	// DBStartup is a starting up cluster
+	DBStartup DBState = iota
+	// DBShutdowned is a cleanly shut down cluster
+	DBShutdowned
+	// DBShutdownedInRecovery is a standby that was cleanly shut down
+	DBShutdownedInRecovery
+	// DBShutdowning is a shutting down cluster
+	DBShutdowning
+	// DBInCrashRecovery is a cluster replaying WAL after a crash
+	DBInCrashRecovery
+	// DBInArchiveRecovery is a cluster replaying WAL from the archive or a primary
+	DBInArchiveRecovery
+	// DBInProduction is a running cluster, or one that crashed
+	DBInProduction
+
+	controlFileMinSize = 20
+)
+
+// ControlFile contains the stable fields at the start of ControlFileData
+type ControlFile struct {
+	SystemIdentifier uint64
+	Version          uint32
+	CatalogVersion   uint32
+	State            DBState
+}
+
+// ReadControlFile parses the beginning of global/pg_control
+func ReadControlFile(pgData string) (controlFile ControlFile, err error) {
+	content, err := os.ReadFile(path.Join(pgData, "global", "pg_control"))
+	if err != nil {
+		return controlFile, fmt.Errorf("error reading pg_control: %v", err)
+	}
+	if len(content) < controlFileMinSize {
+		return controlFile, fmt.Errorf("pg_control is too small")
+	}
+	controlFile.SystemIdentifier = binary.NativeEndian.Uint64(content[0:])
+	controlFile.Version = binary.NativeEndian.Uint32(content[8:])
+	controlFile.CatalogVersion = binary.NativeEndian.Uint32(content[12:])
+	controlFile.State = DBState(binary.NativeEndian.Uint32(content[16:]))
+	return
+}
+
+// String returns the state as displayed by pg_controldata
+func (s DBState) String() string {
+	switch s {
+	case DBStartup:
+		return "starting up"
+	case DBShutdowned:
+		return "shut down"
+	case DBShutdownedInRecovery:
+		return "shut down in recovery"
+	case DBShutdowning:
+		return "shutting down"
+	case DBInCrashRecovery:
+		return "in crash recovery"
+	case DBInArchiveRecovery:
+		return "in archive recovery"
+	case DBInProduction:
+		return "in production"
+	}
+	return "unrecognized status code"
+}
+
+// ReadVersionFile returns the version stored in a PG_VERSION file in the
+// server_version_num format: 90600 for 9.6, 160000 for 16
+func ReadVersionFile(dir string) (int, error) {
+	content, err := os.ReadFile(path.Join(dir, "PG_VERSION"))
+	if err != nil {
+		return 0, fmt.Errorf("error reading PG_VERSION: %v", err)
+	}
+	major, minor, found := strings.Cut(strings.TrimSpace(string(content)), ".")
+	majorNum, err := strconv.Atoi(major)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing PG_VERSION: %v", err)
+	}
+	if !found {
+		return majorNum * 10000, nil
+	}
+	minorNum, err := strconv.Atoi(minor)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing PG_VERSION: %v", err)
+	}
+	return majorNum*10000 + minorNum*100, nil
+}
+
+// DetectPageSize returns the page size stored in the first page header of a
+// relation file
+func DetectPageSize(filePath string) (int, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, PageHeaderSize)
+	_, err = f.ReadAt(buf, 0)
+	if err != nil {
+		return 0, fmt.Errorf("error reading page header of %s: %v", filePath, err)
+	}
+	h, err := ParsePageHeader(buf)
+	if err != nil {
+		return 0, err
+	}
+	if h.PageSize() == 0 {
+		return 0, fmt.Errorf("no page size found in %s", filePath)
+	}
+	return h.PageSize(), nil
+}
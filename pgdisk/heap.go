@@ -0,0 +1,191 @@
+package pgdisk
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	// heapTupleHeaderSize is the size of HeapTupleHeaderData without the null bitmap
+	heapTupleHeaderSize = 23
+
+	// t_infomask2 bits
+	heapNattsMask = 0x07FF
+
+	// t_infomask bits
+	heapXmaxKeyshrLock = 0x0010
+	heapHasOidOld      = 0x0008
+	heapXmaxExclLock   = 0x0040
+	heapXmaxLockOnly   = 0x0080
+	heapXminCommitted  = 0x0100
+	heapXminInvalid    = 0x0200
+	heapXminFrozen     = heapXminCommitted | heapXminInvalid
+	heapXmaxCommitted  = 0x0400
+	heapXmaxInvalid    = 0x0800
+	heapXmaxIsMulti    = 0x1000
+	heapLockMask       = heapXmaxKeyshrLock | heapXmaxExclLock
+
+	// firstNormalTransactionID is the first xid that isn't bootstrap or frozen
+	firstNormalTransactionID = 3
+)
+
+// HeapTuple represents a heap tuple with its header fields
+type HeapTuple struct {
+	Xmin      uint32
+	Xmax      uint32
+	Infomask2 uint16
+	Infomask  uint16
+	Hoff      uint8
+	// Oid is only set for catalogs created before PostgreSQL 12 which stored
+	// the oid in the tuple header
+	Oid uint32
+	// Data contains the user data, starting at t_hoff
+	Data []byte
+}
+
+// Natts returns the number of attributes stored in the tuple
+func (t HeapTuple) Natts() int {
+	return int(t.Infomask2 & heapNattsMask)
+}
+
+// xmaxIsLockedOnly returns true if xmax only locked the tuple
+func (t HeapTuple) xmaxIsLockedOnly() bool {
+	return t.Infomask&heapXmaxLockOnly != 0 ||
+		t.Infomask&(heapXmaxIsMulti|heapLockMask) == heapXmaxExclLock
+}
+
+// ParseHeapTuples returns all normal tuples of a heap page
+func ParseHeapTuples(page []byte) (tuples []HeapTuple, err error) {
+	h, err := ParsePageHeader(page)
+	if err != nil || h.IsNew() {
+		return
+	}
+	if !h.IsValid(len(page)) {
+		return nil, fmt.Errorf("invalid page header: %+v", h)
+	}
+
+	for _, itemID := range h.ItemIDs(page) {
+		if itemID.Flags != lpNormal {
+			continue
+		}
+		start := int(itemID.Off)
+		end := start + int(itemID.Len)
+		if itemID.Len < heapTupleHeaderSize || end > len(page) {
+			return nil, fmt.Errorf("invalid line pointer: %+v", itemID)
+		}
+		raw := page[start:end]
+
+		var t HeapTuple
+		t.Xmin = binary.NativeEndian.Uint32(raw[0:])
+		t.Xmax = binary.NativeEndian.Uint32(raw[4:])
+		t.Infomask2 = binary.NativeEndian.Uint16(raw[18:])
+		t.Infomask = binary.NativeEndian.Uint16(raw[20:])
+		t.Hoff = raw[22]
+		if int(t.Hoff) < heapTupleHeaderSize || int(t.Hoff) > len(raw) {
+			return nil, fmt.Errorf("invalid t_hoff %d for tuple of %d bytes", t.Hoff, len(raw))
+		}
+		if t.Infomask&heapHasOidOld != 0 {
+			if int(t.Hoff)-4 < heapTupleHeaderSize {
+				return nil, fmt.Errorf("invalid t_hoff %d for tuple with oid", t.Hoff)
+			}
+			// Oid is stored at the end of the header
+			t.Oid = binary.NativeEndian.Uint32(raw[t.Hoff-4:])
+		}
+		t.Data = raw[t.Hoff:]
+		tuples = append(tuples, t)
+	}
+	return
+}
+
+// IsVisible returns true if the tuple was inserted by a committed transaction
+// and not deleted by a committed transaction. Transactions still in progress
+// are considered aborted as this is only used on a stopped cluster.
+func (c *Clog) IsVisible(t HeapTuple) (bool, error) {
+	switch {
+	case t.Infomask&heapXminFrozen == heapXminFrozen:
+	case t.Infomask&heapXminCommitted != 0:
+	case t.Infomask&heapXminInvalid != 0:
+		return false, nil
+	default:
+		status, err := c.GetStatus(t.Xmin)
+		if err != nil {
+			return false, err
+		}
+		if status != XactCommitted {
+			return false, nil
+		}
+	}
+
+	switch {
+	case t.Infomask&heapXmaxInvalid != 0:
+		return true, nil
+	case t.xmaxIsLockedOnly():
+		return true, nil
+	case t.Infomask&heapXmaxIsMulti != 0:
+		// Resolving multixact members is not supported, assume the tuple is alive
+		return true, nil
+	case t.Infomask&heapXmaxCommitted != 0:
+		return false, nil
+	}
+	status, err := c.GetStatus(t.Xmax)
+	if err != nil {
+		return false, err
+	}
+	return status != XactCommitted, nil
+}
+
+// ScanHeap calls fn on all visible tuples of a relation's main fork,
+// going through all segments. Tuple data is only valid during fn's call.
+func ScanHeap(basePath string, pageSize int, clog *Clog, fn func(HeapTuple) error) error {
+	for segno := 0; ; segno++ {
+		fullPath := basePath
+		if segno > 0 {
+			fullPath = fmt.Sprintf("%s.%d", basePath, segno)
+		}
+		f, err := os.Open(fullPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && segno > 0 {
+				// Last segment was processed
+				return nil
+			}
+			return err
+		}
+
+		page := make([]byte, pageSize)
+		for blkno := 0; ; blkno++ {
+			_, err = io.ReadFull(f, page)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("error reading block %d of %s: %v", blkno, fullPath, err)
+			}
+
+			tuples, err := ParseHeapTuples(page)
+			if err != nil {
+				f.Close()
+				return fmt.Errorf("error parsing block %d of %s: %v", blkno, fullPath, err)
+			}
+			for _, t := range tuples {
+				visible, err := clog.IsVisible(t)
+				if err != nil {
+					f.Close()
+					return err
+				}
+				if !visible {
+					continue
+				}
+				err = fn(t)
+				if err != nil {
+					f.Close()
+					return err
+				}
+			}
+		}
+		f.Close()
+	}
+}
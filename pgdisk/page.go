@@ -0,0 +1,90 @@
+package pgdisk
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// PageHeaderSize is the size of PageHeaderData
+	PageHeaderSize = 24
+	// ItemIDSize is the size of a line pointer
+	ItemIDSize = 4
+
+	// Line pointer flags
+	lpUnused   = 0
+	lpNormal   = 1
+	lpRedirect = 2
+	lpDead     = 3
+)
+
+// PageHeader represents PostgreSQL's PageHeaderData
+type PageHeader struct {
+	Lsn             uint64
+	Checksum        uint16
+	Flags           uint16
+	Lower           uint16
+	Upper           uint16
+	Special         uint16
+	PageSizeVersion uint16
+	PruneXid        uint32
+}
+
+// ItemID represents a line pointer
+type ItemID struct {
+	Off   uint16
+	Flags uint8
+	Len   uint16
+}
+
+// ParsePageHeader parses the header of a page
+func ParsePageHeader(page []byte) (h PageHeader, err error) {
+	if len(page) < PageHeaderSize {
+		return h, fmt.Errorf("page too small: %d bytes", len(page))
+	}
+	// pd_lsn is stored as two 32 bits values: xlogid and xrecoff
+	h.Lsn = uint64(binary.NativeEndian.Uint32(page[0:]))<<32 | uint64(binary.NativeEndian.Uint32(page[4:]))
+	h.Checksum = binary.NativeEndian.Uint16(page[8:])
+	h.Flags = binary.NativeEndian.Uint16(page[10:])
+	h.Lower = binary.NativeEndian.Uint16(page[12:])
+	h.Upper = binary.NativeEndian.Uint16(page[14:])
+	h.Special = binary.NativeEndian.Uint16(page[16:])
+	h.PageSizeVersion = binary.NativeEndian.Uint16(page[18:])
+	h.PruneXid = binary.NativeEndian.Uint32(page[20:])
+	return
+}
+
+// IsNew returns true if the page was never initialised
+func (h PageHeader) IsNew() bool {
+	return h.Upper == 0
+}
+
+// PageSize returns the page size stored in the header
+func (h PageHeader) PageSize() int {
+	return int(h.PageSizeVersion & 0xFF00)
+}
+
+// IsValid checks the header's pointers are consistent with the page size
+func (h PageHeader) IsValid(pageSize int) bool {
+	return h.PageSize() == pageSize && h.Lower >= PageHeaderSize &&
+		h.Lower <= h.Upper && h.Upper <= h.Special && int(h.Special) <= pageSize
+}
+
+// ItemIDs returns the line pointers of the page
+func (h PageHeader) ItemIDs(page []byte) []ItemID {
+	if h.Lower <= PageHeaderSize {
+		return nil
+	}
+	n := (int(h.Lower) - PageHeaderSize) / ItemIDSize
+	itemIDs := make([]ItemID, 0, n)
+	for i := range n {
+		// lp_off:15, lp_flags:2, lp_len:15
+		word := binary.NativeEndian.Uint32(page[PageHeaderSize+i*ItemIDSize:])
+		itemIDs = append(itemIDs, ItemID{
+			Off:   uint16(word & 0x7FFF),
+			Flags: uint8((word >> 15) & 0x3),
+			Len:   uint16(word >> 17),
+		})
+	}
+	return itemIDs
+}
@@ -0,0 +1,44 @@
+package pgdisk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+)
+
+const (
+	// relMapperMagic is the magic number of pg_filenode.map files
+	relMapperMagic = 0x592717
+	relMapHeader   = 8
+	relMappingSize = 8
+)
+
+// ReadFilenodeMap parses a pg_filenode.map file and returns the mapping
+// between the oid of mapped catalogs and their relfilenode
+func ReadFilenodeMap(filePath string) (filenodeMap map[uint32]uint32, err error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading filenode map: %v", err)
+	}
+	if len(content) < relMapHeader {
+		return nil, fmt.Errorf("filenode map %s is too small", filePath)
+	}
+
+	magic := binary.NativeEndian.Uint32(content[0:])
+	if magic != relMapperMagic {
+		return nil, fmt.Errorf("filenode map %s has an invalid magic number %x", filePath, magic)
+	}
+	numMappings := int(binary.NativeEndian.Uint32(content[4:]))
+	if relMapHeader+numMappings*relMappingSize > len(content) {
+		return nil, fmt.Errorf("filenode map %s has an invalid number of mappings %d", filePath, numMappings)
+	}
+
+	filenodeMap = make(map[uint32]uint32, numMappings)
+	for i := range numMappings {
+		offset := relMapHeader + i*relMappingSize
+		oid := binary.NativeEndian.Uint32(content[offset:])
+		filenode := binary.NativeEndian.Uint32(content[offset+4:])
+		filenodeMap[oid] = filenode
+	}
+	return
+}
@@ -0,0 +1,55 @@
+package pgdisk
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const (
+	// vmBitsPerHeapBlock is the number of visibility map bits per heap block
+	vmBitsPerHeapBlock  = 2
+	vmHeapBlocksPerByte = 8 / vmBitsPerHeapBlock
+
+	vmAllVisible = 0x01
+	vmAllFrozen  = 0x02
+)
+
+// VisibilityMap is the content of a relation's _vm fork
+type VisibilityMap struct {
+	content   []byte
+	blockSize int
+}
+
+// ReadVisibilityMap reads the segments of a relation's visibility map fork.
+// A missing fork is an empty map where no block is all-visible
+func ReadVisibilityMap(segmentPaths []string, blockSize int) (v VisibilityMap, err error) {
+	v.blockSize = blockSize
+	for _, segmentPath := range segmentPaths {
+		content, err := os.ReadFile(segmentPath)
+		if errors.Is(err, os.ErrNotExist) {
+			// Truncated since listed
+			break
+		}
+		if err != nil {
+			return v, fmt.Errorf("error reading visibility map: %v", err)
+		}
+		v.content = append(v.content, content...)
+	}
+	return v, nil
+}
+
+// GetStatus returns the all-visible and all-frozen bits of a heap block
+func (v VisibilityMap) GetStatus(heapBlock int64) (allVisible bool, allFrozen bool) {
+	// Each map page starts with a page header
+	heapBlocksPerPage := int64(v.blockSize-PageHeaderSize) * vmHeapBlocksPerByte
+	mapBlock := heapBlock / heapBlocksPerPage
+	mapByte := (heapBlock % heapBlocksPerPage) / vmHeapBlocksPerByte
+	offset := mapBlock*int64(v.blockSize) + PageHeaderSize + mapByte
+	if offset >= int64(len(v.content)) {
+		// Map wasn't extended to this block yet
+		return false, false
+	}
+	bits := v.content[offset] >> ((heapBlock % vmHeapBlocksPerByte) * vmBitsPerHeapBlock)
+	return bits&vmAllVisible != 0, bits&vmAllFrozen != 0
+}
@@ -0,0 +1,163 @@
+package pgversion
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/jackc/pgx/v5"
+)
+
+// Version is a PostgreSQL version in the server_version_num format
+type Version int
+
+const (
+	// MinSupported is the oldest supported version
+	MinSupported Version = 90600
+	// MaxSupported is the newest supported version
+	MaxSupported Version = 189999
+)
+
+// FromServer fetches the version of the connected server
+func FromServer(ctx context.Context, conn *pgx.Conn) (v Version, err error) {
+	var versionNum int
+	err = conn.QueryRow(ctx, "SELECT current_setting('server_version_num')::int").Scan(&versionNum)
+	if err != nil {
+		return 0, fmt.Errorf("error getting server_version_num: %v", err)
+	}
+	return Version(versionNum), nil
+}
+
+// FromPGData reads the version from the PG_VERSION file of pgdata
+func FromPGData(pgData string) (Version, error) {
+	versionNum, err := pgdisk.ReadVersionFile(pgData)
+	return Version(versionNum), err
+}
+
+// CheckSupported logs a warning if the version is outside the support matrix
+func (v Version) CheckSupported() {
+	if v < MinSupported || v > MaxSupported {
+		slog.Warn("Unsupported PostgreSQL version, results may be incorrect", "version", v.String())
+	}
+}
+
+// String returns the major version as displayed by PG_VERSION
+func (v Version) String() string {
+	if v < 100000 {
+		return fmt.Sprintf("%d.%d", v/10000, (v/100)%100)
+	}
+	return fmt.Sprintf("%d", v/10000)
+}
+
+// WalDir returns the WAL directory, renamed from pg_xlog in 10
+func (v Version) WalDir() string {
+	if v < 100000 {
+		return "pg_xlog"
+	}
+	return "pg_wal"
+}
+
+// Relkinds returns the relkinds with storage or children to report.
+// Partitioned tables were added in 10, partitioned indexes in 11
+func (v Version) Relkinds() []string {
+	relkinds := []string{"r", "i", "t", "m"}
+	if v >= 100000 {
+		relkinds = append(relkinds, "p")
+	}
+	if v >= 110000 {
+		relkinds = append(relkinds, "I")
+	}
+	return relkinds
+}
+
+// XactDir returns the transaction status directory, renamed from pg_clog in 10
+func (v Version) XactDir() string {
+	if v < 100000 {
+		return "pg_clog"
+	}
+	return "pg_xact"
+}
+
+// DefaultLogDir returns the default log_directory, renamed from pg_log in 10
+func (v Version) DefaultLogDir() string {
+	if v < 100000 {
+		return "pg_log"
+	}
+	return "log"
+}
+
+// PgDataDirs returns the pgdata subdirectories holding neither relations nor
+// WAL segments. pg_stat_tmp was removed in 15, WAL summaries were added in 17
+func (v Version) PgDataDirs() []string {
+	dirs := []string{v.XactDir(), "pg_multixact", "pg_subtrans", "pg_commit_ts", "pg_stat", "pg_logical"}
+	if v < 150000 {
+		dirs = append(dirs, "pg_stat_tmp")
+	}
+	if v >= 170000 {
+		dirs = append(dirs, path.Join(v.WalDir(), "summaries"))
+	}
+	return dirs
+}
+
+// CurrentWalLsnFunction returns the function returning the current WAL insert location
+func (v Version) CurrentWalLsnFunction() string {
+	if v < 100000 {
+		return "pg_current_xlog_location"
+	}
+	return "pg_current_wal_lsn"
+}
+
+// LastReplayLsnFunction returns the function returning the last replayed WAL location
+func (v Version) LastReplayLsnFunction() string {
+	if v < 100000 {
+		return "pg_last_xlog_replay_location"
+	}
+	return "pg_last_wal_replay_lsn"
+}
+
+// WalFileNameFunction returns the function returning the WAL segment name of a location
+func (v Version) WalFileNameFunction() string {
+	if v < 100000 {
+		return "pg_xlogfile_name"
+	}
+	return "pg_walfile_name"
+}
+
+// WalLsnDiffFunction returns the function computing the difference between two WAL locations
+func (v Version) WalLsnDiffFunction() string {
+	if v < 100000 {
+		return "pg_xlog_location_diff"
+	}
+	return "pg_wal_lsn_diff"
+}
+
+// HasPartitionRoot returns true if pg_partition_root is available (12+)
+func (v Version) HasPartitionRoot() bool {
+	return v >= 120000
+}
+
+// HasOidColumn returns true if catalogs store oid as a regular column.
+// Before 12, oid was stored in the tuple header
+func (v Version) HasOidColumn() bool {
+	return v >= 120000
+}
+
+// HasRelallfrozen returns true if pg_class has relallfrozen after relallvisible (18+)
+func (v Version) HasRelallfrozen() bool {
+	return v >= 180000
+}
+
+// HasSharedMemorySize returns true if the shared_memory_size setting, the
+// size of the main shared memory area, is available (15+)
+func (v Version) HasSharedMemorySize() bool {
+	return v >= 150000
+}
+
+// HasActualBackendIDs returns true if pg_stat_get_backend_idset returns the
+// backend ids used in temporary relation file names (16+). Before, it
+// returned an index in the local backend status array
+func (v Version) HasActualBackendIDs() bool {
+	return v >= 160000
+}
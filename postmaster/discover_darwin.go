@@ -0,0 +1,15 @@
+//go:build darwin
+
+package postmaster
+
+import "fmt"
+
+// Discover is not supported without /proc
+func Discover() ([]Cluster, error) {
+	return nil, fmt.Errorf("postmaster discovery is not supported on darwin")
+}
+
+// FromPid is not supported without /proc
+func FromPid(pid int) (Cluster, error) {
+	return Cluster{}, fmt.Errorf("target pid is not supported on darwin")
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package postmaster
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// isPostgresProcess checks the process name of the pid
+func isPostgresProcess(pid int) bool {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return false
+	}
+	name := strings.TrimSpace(string(comm))
+	return name == "postgres" || name == "postmaster"
+}
+
+// getParentPid returns the ppid from /proc/<pid>/stat
+func getParentPid(pid int) (int, error) {
+	stat, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// comm can contain spaces, fields start after the closing parenthesis
+	i := strings.LastIndexByte(string(stat), ')')
+	fields := strings.Fields(string(stat[i+1:]))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("invalid stat for pid %d", pid)
+	}
+	return strconv.Atoi(fields[1])
+}
+
+// getDataDir returns the data directory of a postmaster. Postmaster chdirs
+// to its data directory, fallback to the -D argument
+func getDataDir(pid int) (string, error) {
+	dataDir, err := os.Readlink(fmt.Sprintf("/proc/%d/cwd", pid))
+	if err == nil {
+		return dataDir, nil
+	}
+	cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid))
+	if err != nil {
+		return "", err
+	}
+	args := strings.Split(strings.TrimRight(string(cmdline), "\x00"), "\x00")
+	for i, arg := range args {
+		if arg == "-D" && i+1 < len(args) {
+			return args[i+1], nil
+		}
+		if strings.HasPrefix(arg, "-D") {
+			return arg[2:], nil
+		}
+	}
+	return "", fmt.Errorf("data directory of pid %d not found", pid)
+}
+
+// Discover returns running postmasters found in /proc
+func Discover() (clusters []Cluster, err error) {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, fmt.Errorf("error listing /proc: %v", err)
+	}
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || !isPostgresProcess(pid) {
+			continue
+		}
+		ppid, err := getParentPid(pid)
+		if err != nil || isPostgresProcess(ppid) {
+			// Backends and auxiliary processes are children of the postmaster
+			continue
+		}
+
+		dataDir, err := getDataDir(pid)
+		if err != nil {
+			slog.Info("Couldn't find data directory of postmaster", "pid", pid, "error", err)
+			continue
+		}
+		if !path.IsAbs(dataDir) {
+			slog.Info("Ignoring postmaster with relative data directory", "pid", pid, "dataDir", dataDir)
+			continue
+		}
+		cluster, err := ReadPidFile(dataDir)
+		if err != nil {
+			slog.Info("Couldn't read postmaster.pid", "pid", pid, "error", err)
+			continue
+		}
+		if cluster.Pid != pid {
+			slog.Info("Stale postmaster.pid", "pid", pid, "pidFile", cluster.Pid)
+			continue
+		}
+		clusters = append(clusters, cluster)
+	}
+	return
+}
+
+// FromPid returns the cluster of the postmaster pid, with paths resolved
+// through its mount namespace
+func FromPid(pid int) (cluster Cluster, err error) {
+	if !isPostgresProcess(pid) {
+		return cluster, fmt.Errorf("pid %d is not a postgres process", pid)
+	}
+	dataDir, err := getDataDir(pid)
+	if err != nil {
+		return cluster, err
+	}
+	root := fmt.Sprintf("/proc/%d/root", pid)
+	cluster, err = ReadPidFile(path.Join(root, dataDir))
+	if err != nil {
+		return cluster, err
+	}
+	// postmaster.pid stores the pid in the process' pid namespace
+	cluster.Pid = pid
+	cluster.Root = root
+	return cluster, nil
+}
@@ -0,0 +1,146 @@
+package postmaster
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// containerIDRegex matches docker, containerd and cri-o container ids in cgroup paths
+var containerIDRegex = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// Cluster is a running PostgreSQL cluster described by its postmaster.pid
+type Cluster struct {
+	Pid        int
+	DataDir    string
+	Port       int
+	SocketDir  string
+	ListenAddr string
+	// Root is the root directory of the postmaster's mount namespace, empty
+	// when paths are resolved from the host
+	Root string
+}
+
+// HostPath returns the path as seen from the host
+func (c Cluster) HostPath(p string) string {
+	if c.Root == "" {
+		return p
+	}
+	return path.Join(c.Root, p)
+}
+
+// ReadPidFile parses the postmaster.pid file of a data directory
+func ReadPidFile(dataDir string) (c Cluster, err error) {
+	f, err := os.Open(path.Join(dataDir, "postmaster.pid"))
+	if err != nil {
+		return c, fmt.Errorf("error opening postmaster.pid: %v", err)
+	}
+	defer f.Close()
+
+	// Lines are pid, data directory, start time, port, socket directory,
+	// listen address, shared memory key and status
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if len(lines) < 4 {
+		return c, fmt.Errorf("postmaster.pid of %s is incomplete", dataDir)
+	}
+	c.Pid, err = strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return c, fmt.Errorf("error parsing pid from postmaster.pid: %v", err)
+	}
+	c.DataDir = lines[1]
+	c.Port, err = strconv.Atoi(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return c, fmt.Errorf("error parsing port from postmaster.pid: %v", err)
+	}
+	if len(lines) > 4 {
+		c.SocketDir = strings.TrimSpace(lines[4])
+	}
+	if len(lines) > 5 {
+		c.ListenAddr = strings.TrimSpace(lines[5])
+	}
+	return
+}
+
+// ConnectString returns the connection string to reach the cluster, using
+// the unix socket when available
+func (c Cluster) ConnectString() string {
+	host := c.SocketDir
+	if path.IsAbs(host) {
+		host = c.HostPath(host)
+	}
+	if host == "" {
+		host = c.ListenAddr
+	}
+	if host == "" || host == "*" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return fmt.Sprintf("host=%s port=%d", host, c.Port)
+}
+
+// ContainerIdentity returns the hostname of the process' mount namespace with
+// the container id found in its cgroup, falling back to the pid
+func ContainerIdentity(pid int) string {
+	var identity []string
+	hostname, err := os.ReadFile(fmt.Sprintf("/proc/%d/root/etc/hostname", pid))
+	if err == nil && len(strings.TrimSpace(string(hostname))) > 0 {
+		identity = append(identity, strings.TrimSpace(string(hostname)))
+	}
+	cgroup, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
+	if err == nil {
+		containerID := containerIDRegex.FindString(string(cgroup))
+		if containerID != "" {
+			identity = append(identity, containerID[:12])
+		}
+	}
+	if len(identity) == 0 {
+		return fmt.Sprintf("pid %d", pid)
+	}
+	return strings.Join(identity, "/")
+}
+
+// String describes the cluster
+func (c Cluster) String() string {
+	return fmt.Sprintf("pid=%d port=%d data_directory=%s", c.Pid, c.Port, c.DataDir)
+}
+
+// Select returns the cluster matching the selector, either a port or a data
+// directory. Without selector, there should be a single cluster
+func Select(clusters []Cluster, selector string) (Cluster, error) {
+	if len(clusters) == 0 {
+		return Cluster{}, fmt.Errorf("no running postmaster found")
+	}
+	if selector == "" {
+		if len(clusters) == 1 {
+			return clusters[0], nil
+		}
+		return Cluster{}, fmt.Errorf("multiple clusters found, select one by port or data directory:\n%s", listClusters(clusters))
+	}
+
+	port, err := strconv.Atoi(selector)
+	for _, c := range clusters {
+		if err == nil && c.Port == port {
+			return c, nil
+		}
+		if filepath.Clean(c.DataDir) == filepath.Clean(selector) {
+			return c, nil
+		}
+	}
+	return Cluster{}, fmt.Errorf("no cluster matching %s:\n%s", selector, listClusters(clusters))
+}
+
+func listClusters(clusters []Cluster) string {
+	var res []string
+	for _, c := range clusters {
+		res = append(res, c.String())
+	}
+	return strings.Join(res, "\n")
+}
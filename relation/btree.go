@@ -0,0 +1,80 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// BtreeInfo counts the cached blocks of a btree index per page type
+type BtreeInfo struct {
+	Name         string
+	Oid          uint32
+	CachedBlocks int
+	// Types counts cached blocks per pgdisk.BtreePageType
+	Types [pgdisk.BtreeHalfDead + 1]int
+	// InternalTotal is the number of internal pages of the index, unknown without pgstatindex
+	InternalTotal *int64
+}
+
+// BtreeHeaders are the headers of the page type columns
+var BtreeHeaders = []string{"Meta", "Root", "Internal", "Leaf", "Deleted", "Half Dead"}
+
+// GetInternalPages returns the number of internal pages of an index using
+// pgstattuple's pgstatindex. The whole index is read
+func GetInternalPages(ctx context.Context, conn *pgx.Conn, oid uint32) (internalPages int64, err error) {
+	err = conn.QueryRow(ctx, "SELECT internal_pages FROM pgstatindex($1::oid::regclass)", oid).Scan(&internalPages)
+	if err != nil {
+		return 0, fmt.Errorf("Error getting internal pages with pgstatindex: %v", err)
+	}
+	return
+}
+
+// Add adds the cached blocks of another index
+func (b *BtreeInfo) Add(o BtreeInfo) {
+	b.CachedBlocks += o.CachedBlocks
+	for i := range b.Types {
+		b.Types[i] += o.Types[i]
+	}
+	if o.InternalTotal != nil {
+		internalTotal := *o.InternalTotal
+		if b.InternalTotal != nil {
+			internalTotal += *b.InternalTotal
+		}
+		b.InternalTotal = &internalTotal
+	}
+}
+
+// GetInternalPct returns the percentage of resident internal pages, the root included
+func (b *BtreeInfo) GetInternalPct() string {
+	if b.InternalTotal == nil {
+		return ""
+	}
+	if *b.InternalTotal == 0 {
+		// Single level tree, the root is a leaf
+		if b.Types[pgdisk.BtreeRoot] == 0 {
+			return "0.00"
+		}
+		return "100.00"
+	}
+	// pgstatindex counts the root as an internal page
+	internal := b.Types[pgdisk.BtreeInternal] + b.Types[pgdisk.BtreeRoot]
+	return strconv.FormatFloat(100*float64(internal)/float64(*b.InternalTotal), 'f', 2, 64)
+}
+
+// ToStringArray outputs the cached blocks per page type with the resident share of internal pages
+func (b *BtreeInfo) ToStringArray(unit utils.Unit, blockSize int64) []string {
+	res := []string{b.Name, utils.FormatPageValue(b.CachedBlocks, unit, blockSize)}
+	for _, count := range b.Types {
+		res = append(res, utils.FormatPageValue(count, unit, blockSize))
+	}
+	internalTotal := ""
+	if b.InternalTotal != nil {
+		internalTotal = utils.FormatPageValue(int(*b.InternalTotal), unit, blockSize)
+	}
+	return append(res, internalTotal, b.GetInternalPct())
+}
@@ -0,0 +1,154 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strconv"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// BlockBitmap stores a set of block numbers
+type BlockBitmap []uint64
+
+// SharedBuffers stores blocks of the main fork loaded in shared_buffers,
+// indexed by relfilenode
+type SharedBuffers map[uint32]BlockBitmap
+
+// BufferSettings stores the block and segment sizes needed to map shared
+// buffers to relation segment files
+type BufferSettings struct {
+	BlockSize     int64
+	SegmentBlocks int64
+}
+
+// DoubleBufferInfo is the shared_buffers and page cache overlap of a relation, in blocks
+type DoubleBufferInfo struct {
+	Name        string
+	Relfilenode uint32
+	// Both is the number of blocks in shared_buffers and in the page cache
+	Both int
+	// SharedOnly is the number of blocks only in shared_buffers
+	SharedOnly int
+	// OSOnly is the number of blocks only in the page cache
+	OSOnly int
+}
+
+// GetBufferSettings fetches block_size and segment_size in blocks
+func GetBufferSettings(ctx context.Context, conn *pgx.Conn) (s BufferSettings, err error) {
+	err = conn.QueryRow(ctx, `SELECT current_setting('block_size')::bigint,
+	(SELECT setting::bigint FROM pg_settings WHERE name = 'segment_size')`).Scan(&s.BlockSize, &s.SegmentBlocks)
+	if err != nil {
+		return s, fmt.Errorf("Error getting block and segment size: %v", err)
+	}
+	return
+}
+
+// GetSharedBuffersSize returns the configured size of shared_buffers in kb
+func GetSharedBuffersSize(ctx context.Context, conn *pgx.Conn) (size int64, err error) {
+	err = conn.QueryRow(ctx, `SELECT setting::bigint * current_setting('block_size')::bigint / 1024
+	FROM pg_settings WHERE name = 'shared_buffers'`).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("Error getting shared_buffers: %v", err)
+	}
+	return
+}
+
+// GetSharedMemorySize returns the size of the main shared memory area in kb
+// with the setting it was read from. Before 15, only shared_buffers is known
+func GetSharedMemorySize(ctx context.Context, conn *pgx.Conn, version pgversion.Version) (size int64, setting string, err error) {
+	if !version.HasSharedMemorySize() {
+		size, err = GetSharedBuffersSize(ctx, conn)
+		return size, "shared_buffers", err
+	}
+	err = conn.QueryRow(ctx, `SELECT setting::bigint * 1024
+	FROM pg_settings WHERE name = 'shared_memory_size'`).Scan(&size)
+	if err != nil {
+		return 0, "", fmt.Errorf("Error getting shared_memory_size: %v", err)
+	}
+	return size, "shared_memory_size", nil
+}
+
+// GetSharedBuffers returns the main fork blocks of the current database
+// loaded in shared_buffers. It requires the pg_buffercache extension
+func GetSharedBuffers(ctx context.Context, conn *pgx.Conn) (sharedBuffers SharedBuffers, err error) {
+	rows, err := conn.Query(ctx, `SELECT relfilenode, array_agg(relblocknumber::bigint) FROM pg_buffercache
+	WHERE reldatabase = (SELECT oid FROM pg_database WHERE datname = current_database())
+	AND relforknumber = 0
+	GROUP BY relfilenode`)
+	if err != nil {
+		return nil, fmt.Errorf("Error querying pg_buffercache, the extension may be missing: %v", err)
+	}
+
+	sharedBuffers = make(SharedBuffers, 0)
+	for rows.Next() {
+		var relfilenode uint32
+		var blocks []int64
+		err = rows.Scan(&relfilenode, &blocks)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning pg_buffercache: %v", err)
+		}
+		sharedBuffers[relfilenode] = NewBlockBitmap(blocks)
+	}
+	return sharedBuffers, rows.Err()
+}
+
+// NewBlockBitmap builds a bitmap from block numbers
+func NewBlockBitmap(blocks []int64) BlockBitmap {
+	if len(blocks) == 0 {
+		return nil
+	}
+	b := make(BlockBitmap, slices.Max(blocks)/64+1)
+	for _, block := range blocks {
+		b[block/64] |= 1 << (block % 64)
+	}
+	return b
+}
+
+// ForEach calls fn on each block of the bitmap
+func (b BlockBitmap) ForEach(fn func(block int64)) {
+	for i, word := range b {
+		for word != 0 {
+			bit := int64(bits.TrailingZeros64(word))
+			fn(int64(i)*64 + bit)
+			word &= word - 1
+		}
+	}
+}
+
+// Add adds the block counts of the provided info
+func (d *DoubleBufferInfo) Add(b DoubleBufferInfo) {
+	d.Both += b.Both
+	d.SharedOnly += b.SharedOnly
+	d.OSOnly += b.OSOnly
+}
+
+// GetDoublePct returns the percent of the relation's page cache also in shared_buffers
+func (d *DoubleBufferInfo) GetDoublePct() string {
+	if d.Both > 0 {
+		value := 100 * float64(d.Both) / float64(d.Both+d.OSOnly)
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	}
+	return "0"
+}
+
+// ToStringArray outputs the double buffering of the relation. %Total is the
+// part of the page cache wasted by double buffering
+func (d *DoubleBufferInfo) ToStringArray(unit utils.Unit, blockSize int64, fileMemory int64) []string {
+	relfilenode := ""
+	if d.Relfilenode != 0 {
+		relfilenode = fmt.Sprintf("%d", d.Relfilenode)
+	}
+	both := pagecache.PageStats{PageCached: d.Both}
+	return []string{d.Name, relfilenode,
+		utils.FormatPageValue(d.Both, unit, blockSize),
+		utils.FormatPageValue(d.SharedOnly, unit, blockSize),
+		utils.FormatPageValue(d.OSOnly, unit, blockSize),
+		d.GetDoublePct(),
+		both.GetTotalCachedPct(blockSize, fileMemory)}
+}
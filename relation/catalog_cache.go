@@ -0,0 +1,154 @@
+package relation
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// CatalogEntry is a relation as stored in the catalog cache file
+type CatalogEntry struct {
+	Partition    string
+	Table        string
+	Namespace    string
+	Name         string
+	Kind         string
+	Oid          uint32
+	Relfilenode  uint32
+	RelpagesSize int64
+
+	Tablespace    string
+	Owner         string
+	AccessMethod  string
+	PartitionRoot string
+}
+
+// CatalogCache stores the relfilenode to relation mapping with the time it
+// was fetched, allowing to scan without a connection
+type CatalogCache struct {
+	Timestamp time.Time
+	Database  string
+	Dbid      uint32
+	Relations []CatalogEntry
+}
+
+// NewCatalogCache builds a catalog cache from the partition map
+func NewCatalogCache(database string, dbid uint32, partitionMap map[string]PartInfo) (c CatalogCache) {
+	c.Timestamp = time.Now()
+	c.Database = database
+	c.Dbid = dbid
+	for _, partInfo := range partitionMap {
+		for _, tableInfo := range partInfo.TableInfos {
+			for _, relinfo := range tableInfo.RelInfos {
+				c.Relations = append(c.Relations, CatalogEntry{
+					Partition:    relinfo.Partition,
+					Table:        relinfo.Table,
+					Namespace:    relinfo.Namespace,
+					Name:         relinfo.Name,
+					Kind:         string(relinfo.Kind),
+					Oid:          relinfo.Oid,
+					Relfilenode:  relinfo.Relfilenode,
+					RelpagesSize: relinfo.RelpagesSize,
+
+					Tablespace:    relinfo.Tablespace,
+					Owner:         relinfo.Owner,
+					AccessMethod:  relinfo.AccessMethod,
+					PartitionRoot: relinfo.PartitionRoot,
+				})
+			}
+		}
+	}
+	return
+}
+
+// LoadCatalogCache reads a catalog cache file
+func LoadCatalogCache(filePath string) (c CatalogCache, err error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return c, fmt.Errorf("error reading catalog cache: %v", err)
+	}
+	err = json.Unmarshal(content, &c)
+	if err != nil {
+		return c, fmt.Errorf("error parsing catalog cache %s: %v", filePath, err)
+	}
+	return
+}
+
+// writeJSONFile atomically writes the value as JSON in the file
+func writeJSONFile(filePath string, v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmpFile, err := os.CreateTemp(filepath.Dir(filePath), filepath.Base(filePath)+".tmp")
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", filePath, err)
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.Write(content)
+	if err != nil {
+		tmpFile.Close()
+		return fmt.Errorf("error writing %s: %v", filePath, err)
+	}
+	err = tmpFile.Close()
+	if err != nil {
+		return fmt.Errorf("error writing %s: %v", filePath, err)
+	}
+	return os.Rename(tmpFile.Name(), filePath)
+}
+
+// Save atomically writes the catalog cache file
+func (c *CatalogCache) Save(filePath string) error {
+	return writeJSONFile(filePath, c)
+}
+
+// GetPartitionToTables rebuilds the partition map from the cached relations
+func (c *CatalogCache) GetPartitionToTables(tables []string) (partitionMap map[string]PartInfo) {
+	partitionMap = make(map[string]PartInfo, 0)
+	for _, entry := range c.Relations {
+		if len(tables) > 0 && !slices.Contains(tables, entry.Table) {
+			continue
+		}
+		kind, _ := utf8.DecodeRuneInString(entry.Kind)
+		relinfo := RelInfo{
+			BaseInfo:     BaseInfo{Name: entry.Name, Kind: kind},
+			Namespace:    entry.Namespace,
+			Oid:          entry.Oid,
+			Relfilenode:  entry.Relfilenode,
+			RelpagesSize: entry.RelpagesSize,
+
+			Tablespace:    entry.Tablespace,
+			Owner:         entry.Owner,
+			AccessMethod:  entry.AccessMethod,
+			PartitionRoot: entry.PartitionRoot,
+		}
+		addRelInfo(partitionMap, entry.Partition, entry.Table, relinfo)
+	}
+	return
+}
+
+// LogRelfilenodeChanges logs relations whose relfilenode changed between
+// the previous and the current catalog cache
+func (c *CatalogCache) LogRelfilenodeChanges(previous CatalogCache) {
+	previousRelfilenodes := make(map[uint32]uint32, len(previous.Relations))
+	for _, entry := range previous.Relations {
+		previousRelfilenodes[entry.Oid] = entry.Relfilenode
+	}
+	for _, entry := range c.Relations {
+		if entry.Oid == 0 {
+			// Written before oids were tracked
+			continue
+		}
+		previousRelfilenode, ok := previousRelfilenodes[entry.Oid]
+		if ok && previousRelfilenode != entry.Relfilenode {
+			slog.Info("Relfilenode changed", "relation", entry.Namespace+"."+entry.Name,
+				"oid", entry.Oid, "old", previousRelfilenode, "new", entry.Relfilenode,
+				"since", previous.Timestamp)
+		}
+	}
+}
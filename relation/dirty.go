@@ -0,0 +1,72 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// DirtyInfo is the dirty and writeback page cache of a relation or WAL
+type DirtyInfo struct {
+	pagecache.DirtyStats
+	Name string
+	Kind rune
+}
+
+// CheckpointSettings stores the checkpoint configuration with the time until the next timed checkpoint
+type CheckpointSettings struct {
+	Timeout          string
+	MaxWalSize       string
+	CompletionTarget float64
+	// NextCheckpoint is unknown when pg_control_checkpoint isn't accessible
+	NextCheckpoint *time.Duration
+	// DirtyBuffers is the number of dirty shared buffers, unknown without pg_buffercache
+	DirtyBuffers *int64
+}
+
+// GetCheckpointSettings fetches checkpoint settings, the time until the next
+// timed checkpoint and dirty shared buffers
+func GetCheckpointSettings(ctx context.Context, conn *pgx.Conn) (s CheckpointSettings, err error) {
+	err = conn.QueryRow(ctx, `SELECT current_setting('checkpoint_timeout'), current_setting('max_wal_size'),
+	current_setting('checkpoint_completion_target')::float8`).Scan(&s.Timeout, &s.MaxWalSize, &s.CompletionTarget)
+	if err != nil {
+		return s, fmt.Errorf("Error getting checkpoint settings: %v", err)
+	}
+
+	var nextCheckpointSecs float64
+	err = conn.QueryRow(ctx, `SELECT EXTRACT(EPOCH FROM current_setting('checkpoint_timeout')::interval
+	- (now() - (pg_control_checkpoint()).checkpoint_time))::float8`).Scan(&nextCheckpointSecs)
+	if err == nil {
+		nextCheckpoint := time.Duration(nextCheckpointSecs * float64(time.Second))
+		s.NextCheckpoint = &nextCheckpoint
+	} else {
+		slog.Debug("Couldn't get last checkpoint time", "error", err)
+	}
+
+	var dirtyBuffers int64
+	err = conn.QueryRow(ctx, "SELECT count(*) FROM pg_buffercache WHERE isdirty").Scan(&dirtyBuffers)
+	if err == nil {
+		s.DirtyBuffers = &dirtyBuffers
+	} else {
+		slog.Debug("Couldn't get dirty shared buffers", "error", err)
+	}
+	return s, nil
+}
+
+// ToStringArray outputs dirty and writeback pages with the share of the total dirty pages
+func (d *DirtyInfo) ToStringArray(unit utils.Unit, pageSize int64, totalDirty int) []string {
+	dirtyPct := "0"
+	if d.Dirty+d.Writeback > 0 && totalDirty > 0 {
+		dirtyPct = strconv.FormatFloat(100*float64(d.Dirty+d.Writeback)/float64(totalDirty), 'f', 2, 64)
+	}
+	return []string{d.Name, KindToString(d.Kind),
+		utils.FormatPageValue(d.Dirty, unit, pageSize),
+		utils.FormatPageValue(d.Writeback, unit, pageSize),
+		dirtyPct}
+}
@@ -0,0 +1,68 @@
+package relation
+
+import (
+	"strconv"
+
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+// FreeSpaceInfo splits the cached heap blocks of a relation between tuple data and empty space
+type FreeSpaceInfo struct {
+	Name       string
+	Kind       rune
+	PageCached int
+	// CachedBlocks is the number of fully resident blocks that were read
+	CachedBlocks int
+	// Free is the space between pd_lower and pd_upper, in bytes
+	Free int64
+	// Data is the tuple space between pd_upper and pd_special, in bytes
+	Data int64
+	// Overhead is the page header and line pointers, in bytes
+	Overhead int64
+}
+
+// IsHeapKind returns true for relkinds storing heap tuples
+func IsHeapKind(kind rune) bool {
+	return kind == 'r' || kind == 't' || kind == 'm'
+}
+
+// AddBlock adds the space usage of a block from its header pointers.
+// Uninitialised blocks are entirely free
+func (f *FreeSpaceInfo) AddBlock(lower, upper, special uint16, blockSize int64) {
+	f.CachedBlocks++
+	if upper == 0 {
+		f.Free += blockSize
+		return
+	}
+	f.Overhead += int64(lower) + blockSize - int64(special)
+	f.Free += int64(upper) - int64(lower)
+	f.Data += int64(special) - int64(upper)
+}
+
+// Add adds the space usage of another relation
+func (f *FreeSpaceInfo) Add(b FreeSpaceInfo) {
+	f.PageCached += b.PageCached
+	f.CachedBlocks += b.CachedBlocks
+	f.Free += b.Free
+	f.Data += b.Data
+	f.Overhead += b.Overhead
+}
+
+// GetFreePct returns the percentage of empty space in cached blocks
+func (f *FreeSpaceInfo) GetFreePct() string {
+	total := f.Free + f.Data + f.Overhead
+	if total == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(100*float64(f.Free)/float64(total), 'f', 2, 64)
+}
+
+// ToStringArray outputs the cached pages with their free space and effective data
+func (f *FreeSpaceInfo) ToStringArray(unit utils.Unit, pageSize int64) []string {
+	return []string{f.Name, KindToString(f.Kind),
+		utils.FormatPageValue(f.PageCached, unit, pageSize),
+		utils.FormatPageValue(int(f.Data/pageSize), unit, pageSize),
+		utils.FormatPageValue(int(f.Free/pageSize), unit, pageSize),
+		utils.FormatPageValue(int(f.Overhead/pageSize), unit, pageSize),
+		f.GetFreePct()}
+}
@@ -0,0 +1,111 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// NoGroup is the group of relations not returned by the group query
+const NoGroup = "No group"
+
+// GroupLabel is the group and optional sub group of a relation
+type GroupLabel struct {
+	Group    string
+	SubGroup string
+}
+
+// GroupLabels stores labels returned by the group query, indexed by oid or
+// relfilenode depending on the name of the query's first column
+type GroupLabels struct {
+	ByRelfilenode bool
+	Labels        map[uint32]GroupLabel
+}
+
+// toUint32 converts the integer types returned for oid and relfilenode columns
+func toUint32(v any) (uint32, error) {
+	switch n := v.(type) {
+	case uint32:
+		return n, nil
+	case int16:
+		return uint32(n), nil
+	case int32:
+		return uint32(n), nil
+	case int64:
+		return uint32(n), nil
+	}
+	return 0, fmt.Errorf("unexpected type %T, expected oid or integer", v)
+}
+
+// GetGroupLabels runs the user provided group query. It needs to return
+// (oid or relfilenode, group_label[, sub_label])
+func GetGroupLabels(ctx context.Context, conn *pgx.Conn, query string) (labels GroupLabels, err error) {
+	rows, err := conn.Query(ctx, query)
+	if err != nil {
+		return labels, fmt.Errorf("Error running group query: %v", err)
+	}
+	defer rows.Close()
+
+	numFields := len(rows.FieldDescriptions())
+	if numFields < 2 || numFields > 3 {
+		return labels, fmt.Errorf("group query returned %d columns, expected (oid or relfilenode, group_label[, sub_label])", numFields)
+	}
+
+	labels.ByRelfilenode = string(rows.FieldDescriptions()[0].Name) == "relfilenode"
+	labels.Labels = make(map[uint32]GroupLabel, 0)
+	for rows.Next() {
+		var values []any
+		values, err = rows.Values()
+		if err != nil {
+			return labels, fmt.Errorf("Error reading group query result: %v", err)
+		}
+		var id uint32
+		id, err = toUint32(values[0])
+		if err != nil {
+			return labels, fmt.Errorf("Error reading group query first column: %v", err)
+		}
+		var label GroupLabel
+		if values[1] != nil {
+			label.Group = fmt.Sprint(values[1])
+		}
+		if numFields == 3 && values[2] != nil {
+			label.SubGroup = fmt.Sprint(values[2])
+		}
+		labels.Labels[id] = label
+	}
+	return labels, rows.Err()
+}
+
+// get returns the label of the relinfo, looked up by relfilenode or oid
+func (g GroupLabels) get(relinfo RelInfo) (GroupLabel, bool) {
+	if g.ByRelfilenode {
+		label, ok := g.Labels[relinfo.Relfilenode]
+		return label, ok
+	}
+	label, ok := g.Labels[relinfo.Oid]
+	return label, ok
+}
+
+// RegroupPartitions rebuilds the partition map using group labels as partitions
+// and sub labels as tables. Without sub label, the relation stays under its table
+func RegroupPartitions(partitionMap map[string]PartInfo, labels GroupLabels) map[string]PartInfo {
+	groupMap := make(map[string]PartInfo, 0)
+	for _, partInfo := range partitionMap {
+		for _, tableInfo := range partInfo.TableInfos {
+			for _, relinfo := range tableInfo.RelInfos {
+				groupName := NoGroup
+				tableName := relinfo.Table
+				label, ok := labels.get(relinfo)
+				if ok && label.Group != "" {
+					groupName = label.Group
+				}
+				if ok && label.SubGroup != "" {
+					tableName = label.SubGroup
+				}
+				addRelInfo(groupMap, groupName, tableName, relinfo)
+			}
+		}
+	}
+	return groupMap
+}
@@ -0,0 +1,77 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bonnefoa/pg_pagecache/pagecache"
+	"github.com/jackc/pgx/v5"
+)
+
+// historyColumns are the columns of the history table filled by StoreHistory
+var historyColumns = []string{"ts", "host", "database", "relation_oid", "relation",
+	"relfilenode", "fork", "page_count", "page_cached", "page_flags"}
+
+// createHistoryTable creates the history table if it doesn't exist
+func createHistoryTable(ctx context.Context, conn *pgx.Conn, table pgx.Identifier) error {
+	if len(table) > 1 {
+		_, err := conn.Exec(ctx, fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %s", pgx.Identifier{table[0]}.Sanitize()))
+		if err != nil {
+			return fmt.Errorf("error creating history schema: %v", err)
+		}
+	}
+	_, err := conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	ts timestamptz NOT NULL,
+	host text NOT NULL,
+	database text NOT NULL,
+	relation_oid oid NOT NULL,
+	relation text NOT NULL,
+	relfilenode oid NOT NULL,
+	fork text NOT NULL,
+	page_count bigint NOT NULL,
+	page_cached bigint NOT NULL,
+	page_flags jsonb
+)`, table.Sanitize()))
+	if err != nil {
+		return fmt.Errorf("error creating history table: %v", err)
+	}
+	return nil
+}
+
+// flagCounters returns the number of pages for each set of page flags
+func flagCounters(pageFlagsMap map[uint64]pagecache.PageFlags) map[string]int {
+	if len(pageFlagsMap) == 0 {
+		return nil
+	}
+	counters := make(map[string]int, len(pageFlagsMap))
+	for _, pfs := range pageFlagsMap {
+		counters[pagecache.PageFlagLongName(pfs.Flags)] += pfs.Count
+	}
+	return counters
+}
+
+// StoreHistory inserts the relations' scan results in the history table with
+// COPY, creating the table on first use. Table can be schema qualified
+func StoreHistory(ctx context.Context, conn *pgx.Conn, tableName string, host string, database string, relinfos []RelInfo) error {
+	table := pgx.Identifier(strings.Split(tableName, "."))
+	err := createHistoryTable(ctx, conn, table)
+	if err != nil {
+		return err
+	}
+
+	ts := time.Now()
+	rows := make([][]any, 0, len(relinfos))
+	for _, relinfo := range relinfos {
+		// Only the main fork is scanned
+		rows = append(rows, []any{ts, host, database, relinfo.Oid,
+			relinfo.Namespace + "." + relinfo.Name, relinfo.Relfilenode, "main",
+			int64(relinfo.PageCount), int64(relinfo.PageCached), flagCounters(relinfo.PageFlagsMap)})
+	}
+	_, err = conn.CopyFrom(ctx, table, historyColumns, pgx.CopyFromRows(rows))
+	if err != nil {
+		return fmt.Errorf("error copying to history table: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package relation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// ImpactInfo is the page cache change of a relation caused by a query
+type ImpactInfo struct {
+	Name        string
+	Relfilenode uint32
+	Kind        rune
+	Before      int
+	After       int
+	// PulledIn is the number of pages cached by the query
+	PulledIn int
+	// Evicted is the number of pages evicted while the query ran
+	Evicted int
+}
+
+// Add adds page counts of the provided impact
+func (i *ImpactInfo) Add(b ImpactInfo) {
+	i.Before += b.Before
+	i.After += b.After
+	i.PulledIn += b.PulledIn
+	i.Evicted += b.Evicted
+}
+
+// ToStringArray outputs the page cache change of the relation
+func (i *ImpactInfo) ToStringArray(unit utils.Unit, pageSize int64) []string {
+	relfilenode := ""
+	if i.Relfilenode != 0 {
+		relfilenode = fmt.Sprintf("%d", i.Relfilenode)
+	}
+	return []string{i.Name, relfilenode, KindToString(i.Kind),
+		utils.FormatPageValue(i.Before, unit, pageSize),
+		utils.FormatPageValue(i.After, unit, pageSize),
+		utils.FormatPageValue(i.PulledIn, unit, pageSize),
+		utils.FormatPageValue(i.Evicted, unit, pageSize)}
+}
+
+// GetPlanRelations returns the names of relations and indexes scanned or
+// modified by the query's plan. The query is only planned, not executed
+func GetPlanRelations(ctx context.Context, conn *pgx.Conn, query string) (names map[string]bool, err error) {
+	var rawPlan []byte
+	err = conn.QueryRow(ctx, "EXPLAIN (FORMAT JSON) "+query).Scan(&rawPlan)
+	if err != nil {
+		return nil, fmt.Errorf("error planning query: %v", err)
+	}
+	var plan any
+	err = json.Unmarshal(rawPlan, &plan)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing plan: %v", err)
+	}
+	names = make(map[string]bool, 0)
+	collectPlanRelations(plan, names)
+	return names, nil
+}
+
+// collectPlanRelations walks the plan's nodes, including subplans and init
+// plans, adding their relation and index names
+func collectPlanRelations(node any, names map[string]bool) {
+	switch v := node.(type) {
+	case []any:
+		for _, child := range v {
+			collectPlanRelations(child, names)
+		}
+	case map[string]any:
+		for key, value := range v {
+			name, isString := value.(string)
+			if isString && (key == "Relation Name" || key == "Index Name") {
+				names[name] = true
+				continue
+			}
+			collectPlanRelations(value, names)
+		}
+	}
+}
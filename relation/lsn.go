@@ -0,0 +1,116 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// LsnAgeBuckets are the upper bounds of the WAL distance between the page
+// LSN and the current location, after the pages modified since the last checkpoint
+var LsnAgeBuckets = []uint64{1 << 30, 16 << 30, 256 << 30}
+
+// LsnAgeHeaders are the headers of the LSN age columns
+var LsnAgeHeaders = []string{"Since Redo", "<1GB", "<16GB", "<256GB", "Older", "No LSN"}
+
+// LsnPositions stores the reference WAL locations to compute page ages
+type LsnPositions struct {
+	Current uint64
+	// Redo is unknown without access to pg_control_checkpoint
+	Redo *uint64
+}
+
+// LsnInfo is the LSN age distribution of the cached blocks of a relation
+type LsnInfo struct {
+	Name   string
+	Kind   rune
+	Cached int
+	// Ages counts blocks per LsnAgeHeaders column
+	Ages []int
+}
+
+// GetLsnPositions fetches the current WAL location, the replay location on
+// standbys, and the redo location of the last checkpoint when accessible
+func GetLsnPositions(ctx context.Context, conn *pgx.Conn, version pgversion.Version) (l LsnPositions, err error) {
+	query := fmt.Sprintf(`SELECT %s(CASE WHEN pg_is_in_recovery() THEN %s() ELSE %s() END, '0/0')::bigint`,
+		version.WalLsnDiffFunction(), version.LastReplayLsnFunction(), version.CurrentWalLsnFunction())
+	err = conn.QueryRow(ctx, query).Scan(&l.Current)
+	if err != nil {
+		return l, fmt.Errorf("Error getting current WAL location: %v", err)
+	}
+
+	var redo uint64
+	query = fmt.Sprintf(`SELECT %s((pg_control_checkpoint()).redo_lsn, '0/0')::bigint`, version.WalLsnDiffFunction())
+	err = conn.QueryRow(ctx, query).Scan(&redo)
+	if err != nil {
+		// Restricted to superusers and pg_monitor from 10
+		slog.Warn("Couldn't get the last checkpoint redo location, Since Redo won't be reported", "error", err)
+		return l, nil
+	}
+	l.Redo = &redo
+	return l, nil
+}
+
+// GetLsnAgeHeaders returns the headers of the reported LSN age columns
+func (l LsnPositions) GetLsnAgeHeaders() []string {
+	if l.Redo == nil {
+		return LsnAgeHeaders[1:]
+	}
+	return LsnAgeHeaders
+}
+
+// NewLsnInfo creates an empty LSN age distribution
+func NewLsnInfo(name string, kind rune) LsnInfo {
+	return LsnInfo{Name: name, Kind: kind, Ages: make([]int, len(LsnAgeHeaders))}
+}
+
+// AddBlock adds a cached block with the provided page LSN
+func (l *LsnInfo) AddBlock(lsn uint64, positions LsnPositions) {
+	l.Cached++
+	switch {
+	case lsn == 0:
+		// Unlogged relations and pages never WAL-logged
+		l.Ages[len(l.Ages)-1]++
+		return
+	case positions.Redo != nil && lsn >= *positions.Redo:
+		l.Ages[0]++
+		return
+	}
+	age := uint64(0)
+	if positions.Current > lsn {
+		age = positions.Current - lsn
+	}
+	for i, bound := range LsnAgeBuckets {
+		if age < bound {
+			l.Ages[i+1]++
+			return
+		}
+	}
+	l.Ages[len(LsnAgeBuckets)+1]++
+}
+
+// Add adds the distribution of another relation
+func (l *LsnInfo) Add(b LsnInfo) {
+	l.Cached += b.Cached
+	for i := range l.Ages {
+		l.Ages[i] += b.Ages[i]
+	}
+}
+
+// ToStringArray outputs the cached blocks with their age distribution.
+// Since Redo is skipped when the redo location is unknown
+func (l *LsnInfo) ToStringArray(unit utils.Unit, blockSize int64, positions LsnPositions) []string {
+	res := []string{l.Name, KindToString(l.Kind), utils.FormatPageValue(l.Cached, unit, blockSize)}
+	ages := l.Ages
+	if positions.Redo == nil {
+		ages = ages[1:]
+	}
+	for _, count := range ages {
+		res = append(res, utils.FormatPageValue(count, unit, blockSize))
+	}
+	return res
+}
@@ -0,0 +1,419 @@
+package relation
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"path"
+	"slices"
+
+	"github.com/bonnefoa/pg_pagecache/pgdisk"
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+)
+
+const (
+	// Oids of the catalogs we need to read
+	pgClassOid      = 1259
+	pgDatabaseOid   = 1262
+	pgIndexOid      = 2610
+	pgInheritsOid   = 2611
+	pgNamespaceOid  = 2615
+	pgAmOid         = 2601
+	pgTablespaceOid = 1213
+	pgAuthidOid     = 1260
+
+	// defaultTablespace is the tablespace of relations with reltablespace 0.
+	// Catalogs are read from base/<dbid>, so the database uses pg_default
+	defaultTablespace = "pg_default"
+
+	nameDataLen = 64
+)
+
+// classRow contains the pg_class columns needed to build the partition map
+type classRow struct {
+	Oid            uint32
+	Name           string
+	Namespace      uint32
+	Relfilenode    uint32
+	Owner          uint32
+	Relam          uint32
+	Reltablespace  uint32
+	Relpages       int32
+	Reltoastrelid  uint32
+	Relpersistence byte
+	Relkind        byte
+	Relispartition bool
+}
+
+// OfflineCatalog reads catalogs directly from PGDATA's files, without
+// connecting to a running server
+type OfflineCatalog struct {
+	pgData   string
+	version  pgversion.Version
+	pageSize int
+	clog     *pgdisk.Clog
+}
+
+// NewOfflineCatalog checks the cluster state and detects version and page size
+func NewOfflineCatalog(pgData string) (o *OfflineCatalog, err error) {
+	controlFile, err := pgdisk.ReadControlFile(pgData)
+	if err != nil {
+		return
+	}
+	if controlFile.State != pgdisk.DBShutdowned && controlFile.State != pgdisk.DBShutdownedInRecovery {
+		// Catalog changes may still be in WAL or shared_buffers
+		slog.Warn("Cluster was not cleanly shut down, on-disk catalogs may be outdated", "state", controlFile.State.String())
+	}
+
+	o = &OfflineCatalog{pgData: pgData}
+	o.version, err = pgversion.FromPGData(pgData)
+	if err != nil {
+		return nil, err
+	}
+	o.version.CheckSupported()
+
+	pgDatabasePath, err := o.getMappedCatalogPath(path.Join(pgData, "global"), pgDatabaseOid)
+	if err != nil {
+		return nil, err
+	}
+	o.pageSize, err = pgdisk.DetectPageSize(pgDatabasePath)
+	if err != nil {
+		return nil, err
+	}
+	o.clog = pgdisk.NewClog(pgData, o.version.XactDir(), o.pageSize)
+	slog.Info("Opened offline catalog", "version", o.version.String(), "pageSize", o.pageSize,
+		"systemIdentifier", controlFile.SystemIdentifier)
+	return
+}
+
+// getMappedCatalogPath returns the file of a catalog using the directory's pg_filenode.map
+func (o *OfflineCatalog) getMappedCatalogPath(dir string, oid uint32) (string, error) {
+	filenodeMap, err := pgdisk.ReadFilenodeMap(path.Join(dir, "pg_filenode.map"))
+	if err != nil {
+		return "", err
+	}
+	filenode, ok := filenodeMap[oid]
+	if !ok {
+		return "", fmt.Errorf("catalog %d not found in %s's filenode map", oid, dir)
+	}
+	return path.Join(dir, fmt.Sprintf("%d", filenode)), nil
+}
+
+// decodeOidAndName decodes catalogs starting with (oid, name) columns
+func (o *OfflineCatalog) decodeOidAndName(t pgdisk.HeapTuple) (oid uint32, name string, rest []byte, err error) {
+	data := t.Data
+	oid = t.Oid
+	if o.version.HasOidColumn() {
+		if len(data) < 4 {
+			return 0, "", nil, fmt.Errorf("tuple too small: %d bytes", len(data))
+		}
+		oid = binary.NativeEndian.Uint32(data)
+		data = data[4:]
+	}
+	if len(data) < nameDataLen {
+		return 0, "", nil, fmt.Errorf("tuple too small: %d bytes", len(t.Data))
+	}
+	name = string(data[:nameDataLen])
+	if i := bytes.IndexByte(data[:nameDataLen], 0); i >= 0 {
+		name = string(data[:i])
+	}
+	return oid, name, data[nameDataLen:], nil
+}
+
+// decodeClassRow decodes the fixed width columns of a pg_class tuple
+func (o *OfflineCatalog) decodeClassRow(t pgdisk.HeapTuple) (c classRow, err error) {
+	var data []byte
+	c.Oid, c.Name, data, err = o.decodeOidAndName(t)
+	if err != nil {
+		return
+	}
+	// relnamespace, reltype, reloftype, relowner, relam, relfilenode,
+	// reltablespace, relpages, reltuples, relallvisible
+	toastOffset := 40
+	if o.version.HasRelallfrozen() {
+		toastOffset += 4
+	}
+	if len(data) < toastOffset+8 {
+		return c, fmt.Errorf("pg_class tuple too small: %d bytes", len(t.Data))
+	}
+	c.Namespace = binary.NativeEndian.Uint32(data[0:])
+	c.Owner = binary.NativeEndian.Uint32(data[12:])
+	c.Relam = binary.NativeEndian.Uint32(data[16:])
+	c.Relfilenode = binary.NativeEndian.Uint32(data[20:])
+	c.Reltablespace = binary.NativeEndian.Uint32(data[24:])
+	c.Relpages = int32(binary.NativeEndian.Uint32(data[28:]))
+	c.Reltoastrelid = binary.NativeEndian.Uint32(data[toastOffset:])
+	// relhasindex and relisshared are followed by relpersistence and relkind
+	c.Relpersistence = data[toastOffset+6]
+	c.Relkind = data[toastOffset+7]
+	// relnatts, relchecks, relhasrules, relhastriggers, relhassubclass,
+	// relrowsecurity, relforcerowsecurity, relispopulated and relreplident
+	// are followed by relispartition
+	if o.version.HasPartitionRoot() && len(data) > toastOffset+19 {
+		c.Relispartition = data[toastOffset+19] != 0
+	}
+	return
+}
+
+// readOidNames returns the oid to name mapping of catalogs starting with (oid, name) columns
+func (o *OfflineCatalog) readOidNames(catalogFile string) (names map[uint32]string, err error) {
+	names = make(map[uint32]string, 0)
+	err = pgdisk.ScanHeap(catalogFile, o.pageSize, o.clog, func(t pgdisk.HeapTuple) error {
+		oid, name, _, err := o.decodeOidAndName(t)
+		names[oid] = name
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", catalogFile, err)
+	}
+	return
+}
+
+// readSharedOidNames returns the oid to name mapping of a shared catalog
+func (o *OfflineCatalog) readSharedOidNames(oid uint32) (map[uint32]string, error) {
+	catalogFile, err := o.getMappedCatalogPath(path.Join(o.pgData, "global"), oid)
+	if err != nil {
+		return nil, err
+	}
+	return o.readOidNames(catalogFile)
+}
+
+// decodeOidPair decodes catalogs starting with two oid columns like pg_index and pg_inherits
+func decodeOidPair(t pgdisk.HeapTuple) (uint32, uint32, error) {
+	if len(t.Data) < 8 {
+		return 0, 0, fmt.Errorf("tuple too small: %d bytes", len(t.Data))
+	}
+	return binary.NativeEndian.Uint32(t.Data[0:]), binary.NativeEndian.Uint32(t.Data[4:]), nil
+}
+
+// GetDatabase returns the oid of the provided database from pg_database
+func (o *OfflineCatalog) GetDatabase(database string) (dbid uint32, err error) {
+	pgDatabasePath, err := o.getMappedCatalogPath(path.Join(o.pgData, "global"), pgDatabaseOid)
+	if err != nil {
+		return
+	}
+	found := false
+	err = pgdisk.ScanHeap(pgDatabasePath, o.pageSize, o.clog, func(t pgdisk.HeapTuple) error {
+		oid, datname, _, err := o.decodeOidAndName(t)
+		if err != nil {
+			return err
+		}
+		if datname == database {
+			dbid = oid
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error reading pg_database: %v", err)
+	}
+	if !found {
+		return 0, fmt.Errorf("database %s not found in pg_database", database)
+	}
+	return
+}
+
+// GetPartitionToTables rebuilds the same mapping as GetPartitionToTables by
+// reading pg_class, pg_index, pg_inherits and pg_namespace from disk
+func (o *OfflineCatalog) GetPartitionToTables(dbid uint32, tables []string) (partitionMap map[string]PartInfo, err error) {
+	dbDir := path.Join(o.pgData, "base", fmt.Sprintf("%d", dbid))
+	filenodeMap, err := pgdisk.ReadFilenodeMap(path.Join(dbDir, "pg_filenode.map"))
+	if err != nil {
+		return
+	}
+	pgClassPath, err := o.getMappedCatalogPath(dbDir, pgClassOid)
+	if err != nil {
+		return
+	}
+
+	classes := make(map[uint32]classRow, 0)
+	// toast relation -> owning relation
+	toastOwners := make(map[uint32]uint32, 0)
+	err = pgdisk.ScanHeap(pgClassPath, o.pageSize, o.clog, func(t pgdisk.HeapTuple) error {
+		c, err := o.decodeClassRow(t)
+		if err != nil {
+			return err
+		}
+		if c.Relfilenode == 0 {
+			if filenode, ok := filenodeMap[c.Oid]; ok {
+				c.Relfilenode = filenode
+			}
+		}
+		classes[c.Oid] = c
+		if c.Reltoastrelid != 0 {
+			toastOwners[c.Reltoastrelid] = c.Oid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading pg_class: %v", err)
+	}
+
+	// catalogPath returns the main fork of a catalog, using its pg_class entry
+	catalogPath := func(oid uint32) (string, error) {
+		c, ok := classes[oid]
+		if !ok || c.Relfilenode == 0 {
+			return "", fmt.Errorf("catalog %d not found in pg_class", oid)
+		}
+		return path.Join(dbDir, fmt.Sprintf("%d", c.Relfilenode)), nil
+	}
+
+	// index -> indexed relation
+	indexedRelations := make(map[uint32]uint32, 0)
+	// child -> parent
+	inheritParents := make(map[uint32]uint32, 0)
+	// namespace oid -> name
+	namespaces := make(map[uint32]string, 0)
+	// access method oid -> name
+	accessMethods := make(map[uint32]string, 0)
+
+	catalogs := []struct {
+		oid    uint32
+		decode func(pgdisk.HeapTuple) error
+	}{
+		{pgIndexOid, func(t pgdisk.HeapTuple) error {
+			indexrelid, indrelid, err := decodeOidPair(t)
+			indexedRelations[indexrelid] = indrelid
+			return err
+		}},
+		{pgInheritsOid, func(t pgdisk.HeapTuple) error {
+			inhrelid, inhparent, err := decodeOidPair(t)
+			if _, ok := inheritParents[inhrelid]; !ok {
+				inheritParents[inhrelid] = inhparent
+			}
+			return err
+		}},
+		{pgNamespaceOid, func(t pgdisk.HeapTuple) error {
+			oid, nspname, _, err := o.decodeOidAndName(t)
+			namespaces[oid] = nspname
+			return err
+		}},
+		{pgAmOid, func(t pgdisk.HeapTuple) error {
+			oid, amname, _, err := o.decodeOidAndName(t)
+			accessMethods[oid] = amname
+			return err
+		}},
+	}
+	for _, catalog := range catalogs {
+		var catalogFile string
+		catalogFile, err = catalogPath(catalog.oid)
+		if err != nil {
+			return
+		}
+		err = pgdisk.ScanHeap(catalogFile, o.pageSize, o.clog, catalog.decode)
+		if err != nil {
+			return nil, fmt.Errorf("error reading catalog %d: %v", catalog.oid, err)
+		}
+	}
+
+	tablespaces, err := o.readSharedOidNames(pgTablespaceOid)
+	if err != nil {
+		return
+	}
+	roles, err := o.readSharedOidNames(pgAuthidOid)
+	if err != nil {
+		return
+	}
+
+	// getRelation returns the relation matching the oid if it has the expected kind
+	getRelation := func(oid uint32, ok bool, relkind byte) (classRow, bool) {
+		if !ok {
+			return classRow{}, false
+		}
+		c, ok := classes[oid]
+		return c, ok && (relkind == 0 || c.Relkind == relkind)
+	}
+	// coalesce returns the first non empty name
+	coalesce := func(names ...string) string {
+		for _, name := range names {
+			if name != "" {
+				return name
+			}
+		}
+		return ""
+	}
+
+	// getPartitionRoot follows the inheritance tree up to the top-most
+	// partitioned table, like pg_partition_root
+	getPartitionRoot := func(c classRow) string {
+		if !c.Relispartition && c.Relkind != 'p' && c.Relkind != 'I' {
+			return NoPartition
+		}
+		for c.Relispartition {
+			parent, ok := classes[inheritParents[c.Oid]]
+			if !ok {
+				break
+			}
+			c = parent
+		}
+		return c.Name
+	}
+
+	relkinds := o.version.Relkinds()
+	partitionMap = make(map[string]PartInfo, 0)
+	for _, c := range classes {
+		if !slices.Contains(relkinds, string(c.Relkind)) || c.Relpersistence == 't' {
+			continue
+		}
+
+		indrelid, isIndex := indexedRelations[c.Oid]
+		// index to parent table
+		pi, _ := getRelation(indrelid, isIndex, 'r')
+		// toast to parent table
+		toastOwner, isToast := toastOwners[c.Oid]
+		pt, _ := getRelation(toastOwner, isToast, 0)
+		// toast index to toast table to parent table
+		var ppti classRow
+		if pti, ok := getRelation(indrelid, isIndex, 't'); ok {
+			toastOwner, isToast = toastOwners[pti.Oid]
+			ppti, _ = getRelation(toastOwner, isToast, 0)
+		}
+		// parent partition
+		parentOid, hasParent := inheritParents[c.Oid]
+		parent, _ := getRelation(parentOid, hasParent, 0)
+		// parent partition from indexes
+		var parentIdx classRow
+		if pi.Oid != 0 {
+			parentOid, hasParent = inheritParents[pi.Oid]
+			parentIdx, _ = getRelation(parentOid, hasParent, 0)
+		}
+
+		partName := coalesce(parentIdx.Name, parent.Name, NoPartition)
+		tableName := coalesce(ppti.Name, pt.Name, pi.Name, c.Name)
+		if len(tables) > 0 && !slices.Contains(tables, tableName) {
+			continue
+		}
+
+		relinfo := RelInfo{
+			BaseInfo:      BaseInfo{Name: c.Name, Kind: rune(c.Relkind)},
+			Namespace:     namespaces[c.Namespace],
+			Oid:           c.Oid,
+			Relfilenode:   c.Relfilenode,
+			RelpagesSize:  int64(c.Relpages) * int64(o.pageSize),
+			Tablespace:    defaultTablespace,
+			Owner:         roles[c.Owner],
+			AccessMethod:  accessMethods[c.Relam],
+			PartitionRoot: partName,
+		}
+		if c.Reltablespace != 0 {
+			relinfo.Tablespace = tablespaces[c.Reltablespace]
+		}
+		if o.version.HasPartitionRoot() {
+			// Use the owning table, same as the online query
+			table := c
+			for _, t := range []classRow{pi, pt, ppti} {
+				if t.Oid != 0 {
+					table = t
+				}
+			}
+			relinfo.PartitionRoot = getPartitionRoot(table)
+		}
+		if relinfo.Relfilenode == 0 {
+			// Relations without storage, same as the online query
+			relinfo.Relfilenode = c.Oid
+		}
+		addRelInfo(partitionMap, partName, tableName, relinfo)
+	}
+	return
+}
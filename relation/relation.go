@@ -5,15 +5,30 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/bonnefoa/pg_pagecache/pgversion"
 	"github.com/jackc/pgx/v5"
 	"github.com/lib/pq"
 )
 
 // GetPartitionToTables returns the mapping between a parent partition and its children
 // Child includes toast table, toast table index and all indexes of the parent relation
-func GetPartitionToTables(ctx context.Context, conn *pgx.Conn, tables []string, pageThreshold int) (partitionMap map[string]PartInfo, err error) {
-	rows, err := conn.Query(ctx, `SELECT COALESCE(parent_idx.relname, parent.relname, 'No partition'), COALESCE(PPTI.relname, PT.relname, PI.relname, C.relname) as t, C.relname, C.relkind, COALESCE(NULLIF(C.relfilenode, 0), C.oid)
+// relpages is not used for filtering as it is 0 for relations that were never analyzed,
+// page threshold is applied on the scanned page count instead
+func GetPartitionToTables(ctx context.Context, conn *pgx.Conn, tables []string, version pgversion.Version) (partitionMap map[string]PartInfo, err error) {
+	// Before 12, only the direct parent is available
+	partitionRoot := "COALESCE(parent_idx.relname, parent.relname, 'No partition')"
+	if version.HasPartitionRoot() {
+		partitionRoot = `COALESCE((SELECT relname FROM pg_class WHERE oid = pg_partition_root(COALESCE(PPTI.oid, PT.oid, PI.oid, C.oid))), 'No partition')`
+	}
+
+	rows, err := conn.Query(ctx, `SELECT COALESCE(parent_idx.relname, parent.relname, 'No partition'), COALESCE(PPTI.relname, PT.relname, PI.relname, C.relname) as t, C.relname, C.relkind, COALESCE(NULLIF(C.relfilenode, 0), C.oid),
+		C.relpages::bigint * current_setting('block_size')::bigint, N.nspname, C.oid,
+		COALESCE(TS.spcname, ''), pg_get_userbyid(C.relowner), COALESCE(AM.amname, ''), `+partitionRoot+`
 		FROM pg_class C
+		JOIN pg_namespace N ON N.oid = C.relnamespace
+		-- reltablespace is 0 for the database's default tablespace
+		LEFT JOIN pg_tablespace TS ON TS.oid = COALESCE(NULLIF(C.reltablespace, 0), (SELECT dattablespace FROM pg_database WHERE datname = current_database()))
+		LEFT JOIN pg_am AM ON AM.oid = C.relam
 		LEFT JOIN pg_index ON pg_index.indexrelid = C.oid
 		-- index to parent table
 		LEFT JOIN pg_class PI ON pg_index.indrelid = PI.oid AND PI.relkind='r'
@@ -31,8 +46,8 @@ func GetPartitionToTables(ctx context.Context, conn *pgx.Conn, tables []string,
 		-- toast index to toast table
 		LEFT JOIN pg_class PTI ON pg_index.indrelid = PTI.oid AND PTI.relkind='t'
 		LEFT JOIN pg_class PPTI ON PPTI.reltoastrelid = PTI.oid
-		WHERE ($1 OR COALESCE(PPTI.relname, PT.relname, PI.relname, C.relname)=ANY($2)) AND C.relpages > $3 AND C.relkind = ANY('{r,i,t,m,p,I}')
-`, len(tables) == 0, pq.Array(tables), pageThreshold)
+		WHERE ($1 OR COALESCE(PPTI.relname, PT.relname, PI.relname, C.relname)=ANY($2)) AND C.relkind::text = ANY($3) AND C.relpersistence <> 't'
+`, len(tables) == 0, pq.Array(tables), pq.Array(version.Relkinds()))
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting list of relfilenode from pg_class: %v\n", err)
@@ -44,38 +59,66 @@ func GetPartitionToTables(ctx context.Context, conn *pgx.Conn, tables []string,
 		var partName string
 		var tableName string
 		var relinfo RelInfo
-		err = rows.Scan(&partName, &tableName, &relinfo.Name, &relinfo.Kind, &relinfo.Relfilenode)
+		err = rows.Scan(&partName, &tableName, &relinfo.Name, &relinfo.Kind, &relinfo.Relfilenode, &relinfo.RelpagesSize, &relinfo.Namespace, &relinfo.Oid,
+			&relinfo.Tablespace, &relinfo.Owner, &relinfo.AccessMethod, &relinfo.PartitionRoot)
 		if err != nil {
 			return nil, fmt.Errorf("Error getting table to relation from pg_class: %v", err)
 		}
-		partInfo, ok := partitionMap[partName]
-		if !ok {
-			// First time, need to initialise partInfo
-			partInfo.Name = partName
-			partInfo.Kind = 'P'
-			partInfo.TableInfos = make(map[string]TableInfo, 0)
-		}
+		addRelInfo(partitionMap, partName, tableName, relinfo)
+	}
+	return
+}
+
+// addRelInfo adds the relinfo to its table and partition, creating them if needed
+// GetStorageRelfilenodes returns the relfilenodes of every relation with
+// storage of the current database, including sequences and mapped catalogs
+func GetStorageRelfilenodes(ctx context.Context, conn *pgx.Conn) (relfilenodes []uint32, err error) {
+	rows, err := conn.Query(ctx, `SELECT pg_relation_filenode(oid) FROM pg_class
+		WHERE pg_relation_filenode(oid) IS NOT NULL AND relpersistence <> 't'`)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting relfilenodes: %v", err)
+	}
+	defer rows.Close()
 
-		tableInfo, ok := partInfo.TableInfos[tableName]
-		if !ok {
-			// First time seeing table, we just need to copy the table name
-			tableInfo.Name = tableName
-			tableInfo.Partition = partName
-			tableInfo.Kind = 'T'
+	for rows.Next() {
+		var relfilenode uint32
+		err = rows.Scan(&relfilenode)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning relfilenode: %v", err)
 		}
+		relfilenodes = append(relfilenodes, relfilenode)
+	}
+	return relfilenodes, rows.Err()
+}
 
-		relinfo.Partition = partName
-		relinfo.Table = tableName
-		tableInfo.RelInfos = append(tableInfo.RelInfos, relinfo)
+func addRelInfo(partitionMap map[string]PartInfo, partName string, tableName string, relinfo RelInfo) {
+	partInfo, ok := partitionMap[partName]
+	if !ok {
+		// First time, need to initialise partInfo
+		partInfo.Name = partName
+		partInfo.Kind = 'P'
+		partInfo.TableInfos = make(map[string]TableInfo, 0)
+	}
 
-		// And update the maps
-		partInfo.TableInfos[tableName] = tableInfo
-		partitionMap[partName] = partInfo
+	tableInfo, ok := partInfo.TableInfos[tableName]
+	if !ok {
+		// First time seeing table, we just need to copy the table name
+		tableInfo.Name = tableName
+		tableInfo.Partition = partName
+		tableInfo.Kind = 'T'
 	}
-	return
+
+	relinfo.Partition = partName
+	relinfo.Table = tableName
+	tableInfo.RelInfos = append(tableInfo.RelInfos, relinfo)
+
+	// And update the maps
+	partInfo.TableInfos[tableName] = tableInfo
+	partitionMap[partName] = partInfo
 }
 
-func kindToString(kind rune) string {
+// KindToString returns the display name of a relkind or an artificial kind
+func KindToString(kind rune) string {
 	switch kind {
 	case 'r':
 		return "Relation"
@@ -98,6 +141,18 @@ func kindToString(kind rune) string {
 		return "Table"
 	case 'W':
 		return "WAL"
+	case 'X':
+		return "Temp Relation"
+	case 'F':
+		return "Temp File"
+	case 'D':
+		return "PGDATA Dir"
+	case 'R':
+		return "Slot Spill"
+	case 'L':
+		return "Log"
+	case 'U':
+		return "Unattributed"
 	}
 	return "Unkown"
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"maps"
 	"slices"
+	"strconv"
 
 	"github.com/bonnefoa/pg_pagecache/pagecache"
 	"github.com/bonnefoa/pg_pagecache/utils"
@@ -45,13 +46,28 @@ type RelInfo struct {
 	BaseInfo
 	Partition   string
 	Table       string
+	Namespace   string
+	Oid         uint32
 	Relfilenode uint32
+	// Catalog attributes used as aggregation dimensions
+	Tablespace    string
+	Owner         string
+	AccessMethod  string
+	PartitionRoot string
+	// RelpagesSize is the relation size in bytes according to pg_class.relpages
+	RelpagesSize int64
+}
+
+// GroupInfo represents an aggregation of relations sharing the same dimension value
+type GroupInfo struct {
+	BaseInfo
+	// Dimension is the name of the dimension used to group
+	Dimension string
 }
 
 var (
 	// TotalInfo stores the sum of all page stats. Used to display the last sum line.
 	TotalInfo = BaseInfo{Name: "Total", Kind: 'S'}
-	WalInfo   = BaseInfo{Name: "WAL", Kind: 'W'}
 )
 
 // GetPagestats returns the page stats
@@ -61,37 +77,59 @@ func (r *BaseInfo) GetPagestats() pagecache.PageStats {
 
 // ToStringArray outputs baseInfo's information
 func (r *BaseInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
-	return []string{"", "", r.Name, "", kindToString(r.Kind),
+	return []string{"", "", r.Name, "", KindToString(r.Kind),
 		utils.FormatPageValue(r.PageCached, unit, pageSize),
 		utils.FormatPageValue(r.PageCount, unit, pageSize),
 		r.GetCachedPct(),
-		r.GetTotalCachedPct(pageSize, fileMemory)}
+		r.GetTotalCachedPct(pageSize, fileMemory), ""}
+}
+
+// GetStatsDriftPct returns the percent of the on-disk size missing from
+// relpages as a string. A negative value means relpages is bigger than
+// the files on disk.
+func (r *RelInfo) GetStatsDriftPct(pageSize int64) string {
+	diskSize := int64(r.PageCount) * pageSize
+	if diskSize == 0 {
+		return "0"
+	}
+	value := 100 * float64(diskSize-r.RelpagesSize) / float64(diskSize)
+	return strconv.FormatFloat(value, 'f', 2, 64)
 }
 
 // ToStringArray outputs relInfo's information
 func (r *RelInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
 	return []string{r.Partition, r.Table, r.Name, fmt.Sprintf("%d", r.Relfilenode),
-		kindToString(r.Kind), utils.FormatPageValue(r.PageCached, unit, pageSize),
+		KindToString(r.Kind), utils.FormatPageValue(r.PageCached, unit, pageSize),
 		utils.FormatPageValue(r.PageCount, unit, pageSize), r.GetCachedPct(),
-		r.GetTotalCachedPct(pageSize, fileMemory)}
+		r.GetTotalCachedPct(pageSize, fileMemory), r.GetStatsDriftPct(pageSize)}
 }
 
 // ToStringArray outputs tableInfo's information
 func (t *TableInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
-	return []string{t.Partition, t.Name, "", "", kindToString(t.Kind),
+	return []string{t.Partition, t.Name, "", "", KindToString(t.Kind),
 		utils.FormatPageValue(t.PageCached, unit, pageSize),
 		utils.FormatPageValue(t.PageCount, unit, pageSize),
 		t.GetCachedPct(),
-		t.GetTotalCachedPct(pageSize, fileMemory)}
+		t.GetTotalCachedPct(pageSize, fileMemory), ""}
 }
 
 // ToStringArray outputs partInfo's information
 func (p *PartInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
-	return []string{p.Name, "", "", "", kindToString(p.Kind),
+	return []string{p.Name, "", "", "", KindToString(p.Kind),
 		utils.FormatPageValue(p.PageCached, unit, pageSize),
 		utils.FormatPageValue(p.PageCount, unit, pageSize),
 		p.GetCachedPct(),
-		p.GetTotalCachedPct(pageSize, fileMemory)}
+		p.GetTotalCachedPct(pageSize, fileMemory), ""}
+}
+
+// ToStringArray outputs groupInfo's information. Group values are provided
+// by the dimension columns
+func (g *GroupInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
+	return []string{"", "", "", "", g.Dimension,
+		utils.FormatPageValue(g.PageCached, unit, pageSize),
+		utils.FormatPageValue(g.PageCount, unit, pageSize),
+		g.GetCachedPct(),
+		g.GetTotalCachedPct(pageSize, fileMemory), ""}
 }
 
 // ToFlagDetails outputs page cache flags details
@@ -0,0 +1,110 @@
+package relation
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+// RelationState is the scan result of a relation, identified by its oid
+type RelationState struct {
+	Name        string
+	Relfilenode uint32
+	PageCached  int
+}
+
+// ScanState stores the scan results of a run, used to detect rewritten
+// relations in the next run
+type ScanState struct {
+	Timestamp time.Time
+	Dbid      uint32
+	Relations map[uint32]RelationState
+}
+
+// RewriteEvent is a relation that got a new relfilenode between two runs,
+// through VACUUM FULL, CLUSTER, TRUNCATE or REINDEX
+type RewriteEvent struct {
+	Oid            uint32
+	Name           string
+	OldRelfilenode uint32
+	NewRelfilenode uint32
+	// PageCachedLost is the number of cached pages of the old relfilenode
+	PageCachedLost int
+	Since          time.Time
+}
+
+// NewScanState builds the scan state from the scanned relations
+func NewScanState(dbid uint32, relinfos []RelInfo) (s ScanState) {
+	s.Timestamp = time.Now()
+	s.Dbid = dbid
+	s.Relations = make(map[uint32]RelationState, 0)
+	for _, relinfo := range relinfos {
+		s.Relations[relinfo.Oid] = RelationState{
+			Name:        relinfo.Namespace + "." + relinfo.Name,
+			Relfilenode: relinfo.Relfilenode,
+			PageCached:  relinfo.PageCached,
+		}
+	}
+	return
+}
+
+// LoadScanState reads a scan state file
+func LoadScanState(filePath string) (s ScanState, err error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return s, fmt.Errorf("error reading scan state: %v", err)
+	}
+	err = json.Unmarshal(content, &s)
+	if err != nil {
+		return s, fmt.Errorf("error parsing scan state %s: %v", filePath, err)
+	}
+	return
+}
+
+// Save atomically writes the scan state file
+func (s *ScanState) Save(filePath string) error {
+	return writeJSONFile(filePath, s)
+}
+
+// GetRewrites returns relations whose relfilenode changed since the previous
+// state, sorted by lost cached pages
+func (s *ScanState) GetRewrites(previous ScanState) (rewrites []RewriteEvent) {
+	if previous.Dbid != s.Dbid {
+		return nil
+	}
+	for oid, current := range s.Relations {
+		if oid == 0 {
+			// Written before oids were tracked
+			continue
+		}
+		old, ok := previous.Relations[oid]
+		if !ok || old.Relfilenode == current.Relfilenode {
+			continue
+		}
+		rewrites = append(rewrites, RewriteEvent{
+			Oid:            oid,
+			Name:           current.Name,
+			OldRelfilenode: old.Relfilenode,
+			NewRelfilenode: current.Relfilenode,
+			PageCachedLost: old.PageCached,
+			Since:          previous.Timestamp,
+		})
+	}
+	slices.SortFunc(rewrites, func(a, b RewriteEvent) int {
+		return cmp.Or(cmp.Compare(b.PageCachedLost, a.PageCachedLost), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// ToStringArray outputs the rewrite event
+func (r *RewriteEvent) ToStringArray(unit utils.Unit, pageSize int64) []string {
+	return []string{r.Name, fmt.Sprintf("%d", r.Oid),
+		fmt.Sprintf("%d", r.OldRelfilenode), fmt.Sprintf("%d", r.NewRelfilenode),
+		utils.FormatPageValue(r.PageCachedLost, unit, pageSize),
+		r.Since.Format(time.RFC3339)}
+}
@@ -0,0 +1,133 @@
+package relation
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// StatioStats stores shared buffers hits and reads of a relation
+type StatioStats struct {
+	Hits  int64
+	Reads int64
+}
+
+// IoTiming stores the database's average read time, only available with track_io_timing
+type IoTiming struct {
+	Enabled bool
+	// AvgReadTime is the average time of a block read in milliseconds
+	AvgReadTime float64
+}
+
+// StatioInfo correlates shared buffers hits and reads of a relation with its page cache residency
+type StatioInfo struct {
+	Name       string
+	Kind       rune
+	Hits       int64
+	Reads      int64
+	PageCached int
+	PageCount  int
+}
+
+// GetStatioStats returns heap and index blocks hits and reads of user
+// relations, indexed by oid, with the database's io timing
+func GetStatioStats(ctx context.Context, conn *pgx.Conn) (statioStats map[uint32]StatioStats, ioTiming IoTiming, err error) {
+	var blksRead int64
+	var blkReadTime float64
+	err = conn.QueryRow(ctx, `SELECT current_setting('track_io_timing')::bool, blks_read, blk_read_time
+	FROM pg_stat_database WHERE datname = current_database()`).Scan(&ioTiming.Enabled, &blksRead, &blkReadTime)
+	if err != nil {
+		return nil, ioTiming, fmt.Errorf("Error getting database io timing: %v", err)
+	}
+	if blksRead > 0 {
+		ioTiming.AvgReadTime = blkReadTime / float64(blksRead)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT relid, COALESCE(heap_blks_hit, 0), COALESCE(heap_blks_read, 0) FROM pg_statio_user_tables
+	UNION ALL
+	SELECT indexrelid, COALESCE(idx_blks_hit, 0), COALESCE(idx_blks_read, 0) FROM pg_statio_user_indexes`)
+	if err != nil {
+		return nil, ioTiming, fmt.Errorf("Error getting statio stats: %v", err)
+	}
+	defer rows.Close()
+
+	statioStats = make(map[uint32]StatioStats, 0)
+	for rows.Next() {
+		var oid uint32
+		var stats StatioStats
+		err = rows.Scan(&oid, &stats.Hits, &stats.Reads)
+		if err != nil {
+			return nil, ioTiming, fmt.Errorf("Error scanning statio stats: %v", err)
+		}
+		statioStats[oid] = stats
+	}
+	return statioStats, ioTiming, rows.Err()
+}
+
+// GetStatioInfos returns relations with shared buffers activity, sorted by reads
+func GetStatioInfos(relinfos []RelInfo, statioStats map[uint32]StatioStats) (statioInfos []StatioInfo) {
+	for _, relinfo := range relinfos {
+		stats, ok := statioStats[relinfo.Oid]
+		if !ok || stats.Hits+stats.Reads == 0 {
+			continue
+		}
+		statioInfos = append(statioInfos, StatioInfo{
+			Name:       relinfo.Namespace + "." + relinfo.Name,
+			Kind:       relinfo.Kind,
+			Hits:       stats.Hits,
+			Reads:      stats.Reads,
+			PageCached: relinfo.PageCached,
+			PageCount:  relinfo.PageCount,
+		})
+	}
+	slices.SortFunc(statioInfos, func(a, b StatioInfo) int {
+		return cmp.Or(cmp.Compare(b.Reads, a.Reads), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// getResidency returns the fraction of the relation in the page cache
+func (s *StatioInfo) getResidency() float64 {
+	if s.PageCount == 0 {
+		return 0
+	}
+	return float64(s.PageCached) / float64(s.PageCount)
+}
+
+// GetEstimatedOSHits estimates the reads served by the page cache, assuming
+// reads hit the relation's pages uniformly
+func (s *StatioInfo) GetEstimatedOSHits() int64 {
+	return int64(float64(s.Reads) * s.getResidency())
+}
+
+// getAdvice suggests whether the relation needs more shared_buffers or more memory
+func (s *StatioInfo) getAdvice() string {
+	hitRatio := float64(s.Hits) / float64(s.Hits+s.Reads)
+	switch {
+	case hitRatio >= 0.99:
+		return "Served by shared_buffers"
+	case s.getResidency() >= 0.9:
+		return "Reads served by page cache, bigger shared_buffers would help"
+	}
+	return "Reads go to disk, more memory would help"
+}
+
+// ToStringArray outputs the shared buffers and page cache usage of the relation
+func (s *StatioInfo) ToStringArray(ioTiming IoTiming) []string {
+	hitRatio := 100 * float64(s.Hits) / float64(s.Hits+s.Reads)
+	osHits := s.GetEstimatedOSHits()
+	avgReadTime := ""
+	if ioTiming.Enabled {
+		avgReadTime = strconv.FormatFloat(ioTiming.AvgReadTime, 'f', 3, 64)
+	}
+	return []string{s.Name, KindToString(s.Kind),
+		fmt.Sprintf("%d", s.Hits), fmt.Sprintf("%d", s.Reads),
+		strconv.FormatFloat(hitRatio, 'f', 2, 64),
+		strconv.FormatFloat(100*s.getResidency(), 'f', 2, 64),
+		fmt.Sprintf("%d", osHits), fmt.Sprintf("%d", s.Reads-osHits),
+		avgReadTime, s.getAdvice()}
+}
@@ -0,0 +1,111 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// TempInfo represents a temporary file: either a segment of a temporary
+// relation or a sort/hash spill file, attributed to its backend
+type TempInfo struct {
+	BaseInfo
+	Pid         int
+	BackendID   int
+	Relfilenode uint32
+	Query       string
+}
+
+// BackendActivity stores running backends' pid and query
+type BackendActivity struct {
+	// BackendPids maps a backend id, used in temporary relation file names, to its pid
+	BackendPids map[int]int
+	// Queries maps a pid to its current query
+	Queries map[int]string
+}
+
+// ToStringArray outputs tempInfo's information
+func (t *TempInfo) ToStringArray(unit utils.Unit, pageSize int64, fileMemory int64) []string {
+	relfilenode := ""
+	if t.Relfilenode != 0 {
+		relfilenode = fmt.Sprintf("%d", t.Relfilenode)
+	}
+	return []string{"", "", t.Name, relfilenode, KindToString(t.Kind),
+		utils.FormatPageValue(t.PageCached, unit, pageSize),
+		utils.FormatPageValue(t.PageCount, unit, pageSize),
+		t.GetCachedPct(),
+		t.GetTotalCachedPct(pageSize, fileMemory), ""}
+}
+
+// ToTempDetails outputs the backend owning the temporary file with its running query
+func (t *TempInfo) ToTempDetails(unit utils.Unit, pageSize int64) []string {
+	pid := ""
+	if t.Pid != 0 {
+		pid = fmt.Sprintf("%d", t.Pid)
+	}
+	return []string{pid, t.Name, KindToString(t.Kind),
+		utils.FormatPageValue(t.PageCached, unit, pageSize),
+		strings.Join(strings.Fields(t.Query), " ")}
+}
+
+// GetTempRelations returns the mapping between relfilenode and name of temporary relations
+func GetTempRelations(ctx context.Context, conn *pgx.Conn) (tempRelations map[uint32]string, err error) {
+	rows, err := conn.Query(ctx, `SELECT C.relfilenode, C.relname FROM pg_class C WHERE C.relpersistence = 't' AND C.relfilenode <> 0`)
+	if err != nil {
+		return nil, fmt.Errorf("Error getting temporary relations from pg_class: %v", err)
+	}
+
+	tempRelations = make(map[uint32]string, 0)
+	for rows.Next() {
+		var relfilenode uint32
+		var relname string
+		err = rows.Scan(&relfilenode, &relname)
+		if err != nil {
+			return nil, fmt.Errorf("Error scanning temporary relation: %v", err)
+		}
+		tempRelations[relfilenode] = relname
+	}
+	return
+}
+
+// GetBackendActivity returns the running backends from pg_stat_activity.
+// Backend ids can only be mapped to pids starting with PostgreSQL 16
+func GetBackendActivity(ctx context.Context, conn *pgx.Conn, version pgversion.Version) (activity BackendActivity, err error) {
+	activity.BackendPids = make(map[int]int, 0)
+	activity.Queries = make(map[int]string, 0)
+
+	if version.HasActualBackendIDs() {
+		var rows pgx.Rows
+		rows, err = conn.Query(ctx, `SELECT backendid, pg_stat_get_backend_pid(backendid) FROM pg_stat_get_backend_idset() AS backendid`)
+		if err != nil {
+			return activity, fmt.Errorf("Error getting backend ids: %v", err)
+		}
+		for rows.Next() {
+			var backendID, pid int
+			err = rows.Scan(&backendID, &pid)
+			if err != nil {
+				return activity, fmt.Errorf("Error scanning backend id: %v", err)
+			}
+			activity.BackendPids[backendID] = pid
+		}
+	}
+
+	rows, err := conn.Query(ctx, `SELECT pid, COALESCE(query, '') FROM pg_stat_activity`)
+	if err != nil {
+		return activity, fmt.Errorf("Error getting pg_stat_activity: %v", err)
+	}
+	for rows.Next() {
+		var pid int
+		var query string
+		err = rows.Scan(&pid, &query)
+		if err != nil {
+			return activity, fmt.Errorf("Error scanning pg_stat_activity: %v", err)
+		}
+		activity.Queries[pid] = query
+	}
+	return
+}
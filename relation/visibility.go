@@ -0,0 +1,45 @@
+package relation
+
+import (
+	"strconv"
+
+	"github.com/bonnefoa/pg_pagecache/utils"
+)
+
+// VisibilityInfo splits the cached heap blocks of a relation using the visibility map
+type VisibilityInfo struct {
+	Name         string
+	Kind         rune
+	CachedBlocks int
+	// AllVisible is the number of cached blocks all-visible but not all-frozen
+	AllVisible int
+	AllFrozen  int
+	// NotVisible is the number of cached blocks needing heap fetches in index-only scans
+	NotVisible int
+}
+
+// Add adds the cached blocks of another relation
+func (v *VisibilityInfo) Add(b VisibilityInfo) {
+	v.CachedBlocks += b.CachedBlocks
+	v.AllVisible += b.AllVisible
+	v.AllFrozen += b.AllFrozen
+	v.NotVisible += b.NotVisible
+}
+
+// GetNotVisiblePct returns the percentage of cached blocks that are not all-visible
+func (v *VisibilityInfo) GetNotVisiblePct() string {
+	if v.CachedBlocks == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(100*float64(v.NotVisible)/float64(v.CachedBlocks), 'f', 2, 64)
+}
+
+// ToStringArray outputs the cached blocks per visibility status
+func (v *VisibilityInfo) ToStringArray(unit utils.Unit, blockSize int64) []string {
+	return []string{v.Name, KindToString(v.Kind),
+		utils.FormatPageValue(v.CachedBlocks, unit, blockSize),
+		utils.FormatPageValue(v.AllVisible, unit, blockSize),
+		utils.FormatPageValue(v.AllFrozen, unit, blockSize),
+		utils.FormatPageValue(v.NotVisible, unit, blockSize),
+		v.GetNotVisiblePct()}
+}
@@ -0,0 +1,103 @@
+package relation
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bonnefoa/pg_pagecache/pgversion"
+	"github.com/jackc/pgx/v5"
+)
+
+// WAL file classes, in display order
+const (
+	WalCurrent  = "Current"
+	WalReady    = "Ready"
+	WalArchived = "Archived"
+	WalRetained = "Retained"
+	WalRecycled = "Recycled"
+	WalHistory  = "History"
+	WalOther    = "Other"
+)
+
+// WalClasses lists WAL file classes in display order
+var WalClasses = []string{WalCurrent, WalReady, WalArchived, WalRetained, WalRecycled, WalHistory, WalOther}
+
+// walSegmentRegex matches WAL segment names: timeline, log id and segment
+var walSegmentRegex = regexp.MustCompile(`^([0-9A-F]{8})([0-9A-F]{8})([0-9A-F]{8})$`)
+
+// WalPosition is the WAL segment being written or replayed
+type WalPosition struct {
+	Timeline uint32
+	Segno    uint64
+}
+
+// GetWalPosition returns the position of the current WAL location, the
+// replay location on standbys, with the WAL segment size. The position is
+// unknown when the standby hasn't replayed anything. The timeline is only
+// known on primaries
+func GetWalPosition(ctx context.Context, conn *pgx.Conn, version pgversion.Version) (position *WalPosition, segmentSize int64, err error) {
+	var lsn *int64
+	var walFileName *string
+	query := fmt.Sprintf(`SELECT %s(CASE WHEN pg_is_in_recovery() THEN %s() ELSE %s() END, '0/0')::bigint,
+	pg_size_bytes(current_setting('wal_segment_size')),
+	CASE WHEN NOT pg_is_in_recovery() THEN %s(%s()) END`,
+		version.WalLsnDiffFunction(), version.LastReplayLsnFunction(), version.CurrentWalLsnFunction(),
+		version.WalFileNameFunction(), version.CurrentWalLsnFunction())
+	err = conn.QueryRow(ctx, query).Scan(&lsn, &segmentSize, &walFileName)
+	if err != nil {
+		return nil, 0, fmt.Errorf("Error getting current WAL location: %v", err)
+	}
+	if lsn == nil {
+		return nil, segmentSize, nil
+	}
+	position = &WalPosition{Segno: uint64(*lsn) / uint64(segmentSize)}
+	if walFileName != nil {
+		position.Timeline, _, _ = ParseWalSegno(*walFileName, segmentSize)
+	}
+	return position, segmentSize, nil
+}
+
+// ParseWalSegno returns the timeline and the segment number of a WAL segment name
+func ParseWalSegno(name string, segmentSize int64) (uint32, uint64, bool) {
+	res := walSegmentRegex.FindStringSubmatch(name)
+	if res == nil {
+		return 0, 0, false
+	}
+	timeline, _ := strconv.ParseUint(res[1], 16, 32)
+	logID, _ := strconv.ParseUint(res[2], 16, 32)
+	seg, _ := strconv.ParseUint(res[3], 16, 32)
+	segmentsPerLogID := uint64(0x100000000) / uint64(segmentSize)
+	return uint32(timeline), logID*segmentsPerLogID + seg, true
+}
+
+// ClassifyWalFile returns the class of a WAL file. Segments before the current
+// one are classified with their archive status, ready or done. Segments of
+// previous timelines are past segments whatever their number
+func ClassifyWalFile(name string, current WalPosition, segmentSize int64, archiveStatus map[string]string) string {
+	if strings.HasSuffix(name, ".history") {
+		return WalHistory
+	}
+	timeline, segno, ok := ParseWalSegno(name, segmentSize)
+	if !ok || timeline > current.Timeline {
+		// Partial segments, backup history files, unknown timelines
+		return WalOther
+	}
+	switch {
+	case timeline < current.Timeline:
+	case segno == current.Segno:
+		return WalCurrent
+	case segno > current.Segno:
+		return WalRecycled
+	}
+	switch archiveStatus[name] {
+	case "ready":
+		return WalReady
+	case "done":
+		return WalArchived
+	}
+	// Kept by wal_keep_size, replication slots or the next checkpoint
+	return WalRetained
+}
@@ -0,0 +1,111 @@
+package relation
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bonnefoa/pg_pagecache/utils"
+	"github.com/jackc/pgx/v5"
+)
+
+// AccessStats stores the scan counters of a user relation
+type AccessStats struct {
+	Scans int64
+	// Unique is true for indexes enforcing a unique or primary key constraint
+	Unique bool
+}
+
+// WasteInfo is a cached relation with no or minimal access since the last stats reset
+type WasteInfo struct {
+	Name       string
+	Kind       rune
+	Oid        uint32
+	PageCached int
+	Scans      int64
+	StatsReset *time.Time
+	Action     string
+}
+
+// GetAccessStats returns seq and idx scans of user tables and idx scans of
+// user indexes, indexed by oid, with the stats reset time of the database
+func GetAccessStats(ctx context.Context, conn *pgx.Conn) (accessStats map[uint32]AccessStats, statsReset *time.Time, err error) {
+	err = conn.QueryRow(ctx, "SELECT stats_reset FROM pg_stat_database WHERE datname = current_database()").Scan(&statsReset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting stats reset time: %v", err)
+	}
+
+	rows, err := conn.Query(ctx, `SELECT relid, COALESCE(seq_scan, 0) + COALESCE(idx_scan, 0), false FROM pg_stat_user_tables
+	UNION ALL
+	SELECT S.indexrelid, S.idx_scan, I.indisunique OR I.indisprimary
+	FROM pg_stat_user_indexes S JOIN pg_index I ON I.indexrelid = S.indexrelid`)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error getting access stats: %v", err)
+	}
+	defer rows.Close()
+
+	accessStats = make(map[uint32]AccessStats, 0)
+	for rows.Next() {
+		var oid uint32
+		var stats AccessStats
+		err = rows.Scan(&oid, &stats.Scans, &stats.Unique)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error scanning access stats: %v", err)
+		}
+		accessStats[oid] = stats
+	}
+	return accessStats, statsReset, rows.Err()
+}
+
+// suggestAction returns the suggested action for a cached relation with few scans
+func suggestAction(kind rune, stats AccessStats) string {
+	isIndex := kind == 'i' || kind == 'I'
+	switch {
+	case isIndex && stats.Unique:
+		return "Index enforces a constraint, keep it"
+	case isIndex && stats.Scans == 0:
+		return "Unused index, consider dropping it"
+	case isIndex:
+		return "Rarely used index, check if it can be dropped"
+	case stats.Scans == 0:
+		return "Unused table, consider archiving or dropping it"
+	}
+	return "Rarely accessed table, check if its cache usage is expected"
+}
+
+// GetWasteInfos returns cached relations with scans under the threshold,
+// sorted by cached pages
+func GetWasteInfos(relinfos []RelInfo, accessStats map[uint32]AccessStats, statsReset *time.Time, scanThreshold int64) (wasteInfos []WasteInfo) {
+	for _, relinfo := range relinfos {
+		stats, ok := accessStats[relinfo.Oid]
+		if !ok || relinfo.PageCached == 0 || stats.Scans > scanThreshold {
+			continue
+		}
+		wasteInfos = append(wasteInfos, WasteInfo{
+			Name:       relinfo.Namespace + "." + relinfo.Name,
+			Kind:       relinfo.Kind,
+			Oid:        relinfo.Oid,
+			PageCached: relinfo.PageCached,
+			Scans:      stats.Scans,
+			StatsReset: statsReset,
+			Action:     suggestAction(relinfo.Kind, stats),
+		})
+	}
+	slices.SortFunc(wasteInfos, func(a, b WasteInfo) int {
+		return cmp.Or(cmp.Compare(b.PageCached, a.PageCached), cmp.Compare(a.Name, b.Name))
+	})
+	return
+}
+
+// ToStringArray outputs the wasted cache of the relation
+func (w *WasteInfo) ToStringArray(unit utils.Unit, pageSize int64) []string {
+	statsReset := "never"
+	if w.StatsReset != nil {
+		statsReset = w.StatsReset.Format(time.RFC3339)
+	}
+	return []string{w.Name, KindToString(w.Kind), fmt.Sprintf("%d", w.Oid),
+		utils.FormatPageValue(w.PageCached, unit, pageSize),
+		fmt.Sprintf("%d", w.Scans), statsReset, w.Action}
+}